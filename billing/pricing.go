@@ -0,0 +1,66 @@
+package billing
+
+import (
+	"strings"
+
+	"github.com/EthanGuo-coder/llm-backend-api/config"
+	"github.com/EthanGuo-coder/llm-backend-api/models"
+	"github.com/EthanGuo-coder/llm-backend-api/providers"
+)
+
+// tokensPerUnit 价格表的计价单位
+const tokensPerUnit = 1_000_000
+
+// ProviderName 返回模型所属服务商名称，无法解析时返回空字符串
+func ProviderName(model string) string {
+	provider, err := providers.Resolve(model)
+	if err != nil {
+		return ""
+	}
+	return provider.Name()
+}
+
+// Price 查找模型的价格。匹配服务商的条目中取模型前缀最长者，前缀相同时指定了服务商的条目优先
+func Price(model string) (*models.PriceConfig, bool) {
+	name := strings.ToLower(model)
+	providerName := ProviderName(model)
+
+	var matched *models.PriceConfig
+	for i := range config.AppConfig.Billing.Prices {
+		price := &config.AppConfig.Billing.Prices[i]
+		if price.Provider != "" && price.Provider != providerName {
+			continue
+		}
+		if !strings.HasPrefix(name, strings.ToLower(price.ModelPrefix)) {
+			continue
+		}
+		if matched == nil || len(price.ModelPrefix) > len(matched.ModelPrefix) ||
+			(len(price.ModelPrefix) == len(matched.ModelPrefix) && matched.Provider == "" && price.Provider != "") {
+			matched = price
+		}
+	}
+	return matched, matched != nil
+}
+
+// Cost 按价格表计算一次回复的费用（美元），用量为空或模型未配置价格时返回 false
+func Cost(model string, usage *models.Usage) (float64, bool) {
+	if usage == nil {
+		return 0, false
+	}
+	price, ok := Price(model)
+	if !ok {
+		return 0, false
+	}
+	cost := (float64(usage.PromptTokens)*price.Input + float64(usage.CompletionTokens)*price.Output) / tokensPerUnit
+	return cost, true
+}
+
+// IsAdmin 判断用户是否可以查看全部用户的费用报表
+func IsAdmin(userID int64) bool {
+	for _, admin := range config.AppConfig.Billing.Admins {
+		if admin == userID {
+			return true
+		}
+	}
+	return false
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/spf13/viper"
 
+	"github.com/EthanGuo-coder/llm-backend-api/constant"
 	"github.com/EthanGuo-coder/llm-backend-api/models"
 )
 
@@ -19,6 +20,26 @@ func LoadConfig(configPath string) error {
 	// 读取环境变量
 	viper.AutomaticEnv()
 
+	// 默认值
+	viper.SetDefault("retry.max_attempts", 3)
+	viper.SetDefault("retry.initial_backoff", 500)
+	viper.SetDefault("retry.max_backoff", 8000)
+	viper.SetDefault("retry.multiplier", 2)
+	viper.SetDefault("retry.max_retry_after", 30)
+	viper.SetDefault("stream.ttl", 300)
+	viper.SetDefault("stream.idle_timeout", 30)
+	viper.SetDefault("context.strategy", constant.ContextDropOldest)
+	viper.SetDefault("context.keep_turns", 10)
+	viper.SetDefault("context.reserve_tokens", 1024)
+	viper.SetDefault("context.summary_max_tokens", 512)
+	viper.SetDefault("memory.threshold_tokens", 6000)
+	viper.SetDefault("memory.keep_turns", 4)
+	viper.SetDefault("memory.max_tokens", 512)
+	viper.SetDefault("files.dir", "./uploads")
+	viper.SetDefault("files.max_size", 10)
+	viper.SetDefault("tools.max_rounds", 5)
+	viper.SetDefault("tools.timeout", 30)
+
 	// 读取配置文件
 	if err := viper.ReadInConfig(); err != nil {
 		return fmt.Errorf("failed to read configuration file: %w", err)
@@ -30,5 +51,52 @@ func LoadConfig(configPath string) error {
 		return fmt.Errorf("failed to parse configuration file: %w", err)
 	}
 
+	// 校验服务商配置，未配置时使用内置服务商
+	if len(AppConfig.Providers) == 0 {
+		AppConfig.Providers = defaultProviders()
+	}
+	if err := validateProviders(AppConfig.Providers); err != nil {
+		return fmt.Errorf("invalid providers configuration: %w", err)
+	}
+	if AppConfig.Retry.MaxAttempts < 1 || AppConfig.Retry.Multiplier < 1 {
+		return fmt.Errorf("invalid retry configuration: max_attempts and multiplier must be at least 1")
+	}
+	// idle_timeout 为 0 时 XREAD BLOCK 会无限等待，续传连接永远不会结束
+	if AppConfig.Stream.IdleTimeout < 1 {
+		return fmt.Errorf("invalid stream configuration: idle_timeout must be at least 1")
+	}
+	switch AppConfig.Context.Strategy {
+	case constant.ContextDropOldest, constant.ContextLastN, constant.ContextSummarize:
+	default:
+		return fmt.Errorf("invalid context configuration: unknown strategy %q", AppConfig.Context.Strategy)
+	}
+	if AppConfig.Context.KeepTurns < 1 {
+		return fmt.Errorf("invalid context configuration: keep_turns must be at least 1")
+	}
+	if AppConfig.Memory.KeepTurns < 1 || AppConfig.Memory.ThresholdTokens < 1 {
+		return fmt.Errorf("invalid memory configuration: keep_turns and threshold_tokens must be at least 1")
+	}
+	for name, limit := range AppConfig.RateLimit.Groups {
+		if limit.Limit < 1 || limit.Window < 1 {
+			return fmt.Errorf("invalid rate_limit configuration: group %q: limit and window must be at least 1", name)
+		}
+	}
+	for _, limits := range []models.QuotaLimits{AppConfig.Quota.Daily, AppConfig.Quota.Monthly} {
+		if limits.Tokens < 0 || limits.Requests < 0 || limits.Cost < 0 {
+			return fmt.Errorf("invalid quota configuration: limits must not be negative")
+		}
+	}
+	for i, price := range AppConfig.Billing.Prices {
+		if price.Provider == "" && price.ModelPrefix == "" {
+			return fmt.Errorf("invalid billing configuration: prices[%d]: provider or model_prefix is required", i)
+		}
+		if price.Input < 0 || price.Output < 0 {
+			return fmt.Errorf("invalid billing configuration: prices[%d]: prices must not be negative", i)
+		}
+	}
+	if AppConfig.Tools.MaxRounds < 1 {
+		return fmt.Errorf("invalid tools configuration: max_rounds must be at least 1")
+	}
+
 	return nil
 }
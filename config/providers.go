@@ -0,0 +1,71 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+
+	"github.com/EthanGuo-coder/llm-backend-api/constant"
+	"github.com/EthanGuo-coder/llm-backend-api/models"
+)
+
+var providerTypes = map[string]bool{
+	constant.ProviderTypeOpenAI:    true,
+	constant.ProviderTypeGLM:       true,
+	constant.ProviderTypeAnthropic: true,
+	constant.ProviderTypeOllama:    true,
+}
+
+var authStyles = map[string]bool{
+	"":                   true, // 按协议默认
+	constant.AuthBearer:  true,
+	constant.AuthAPIKey:  true,
+	constant.AuthXAPIKey: true,
+	constant.AuthNone:    true,
+}
+
+// defaultProviders 未配置 providers 时使用的内置服务商
+func defaultProviders() []models.ProviderConfig {
+	return []models.ProviderConfig{
+		{Name: "openai", Type: constant.ProviderTypeOpenAI, BaseURL: constant.GPTBaseURL, ModelPrefixes: []string{"gpt"}},
+		{Name: "glm", Type: constant.ProviderTypeGLM, BaseURL: constant.GLMBaseURL, ModelPrefixes: []string{"glm"}},
+		{Name: "anthropic", Type: constant.ProviderTypeAnthropic, BaseURL: constant.AnthropicBaseURL, ModelPrefixes: []string{"claude"}},
+		{Name: "ollama", Type: constant.ProviderTypeOllama, BaseURL: constant.OllamaBaseURL, ModelPrefixes: []string{"ollama/"}},
+	}
+}
+
+// validateProviders 校验服务商配置
+func validateProviders(providers []models.ProviderConfig) error {
+	names := make(map[string]bool, len(providers))
+	for i, p := range providers {
+		if p.Name == "" {
+			return fmt.Errorf("providers[%d]: name is required", i)
+		}
+		if names[p.Name] {
+			return fmt.Errorf("providers[%d]: duplicate name %q", i, p.Name)
+		}
+		names[p.Name] = true
+
+		if !providerTypes[p.Type] {
+			return fmt.Errorf("provider %q: unsupported type %q", p.Name, p.Type)
+		}
+		if !authStyles[p.Auth] {
+			return fmt.Errorf("provider %q: unsupported auth %q", p.Name, p.Auth)
+		}
+		if u, err := url.Parse(p.BaseURL); err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("provider %q: invalid base_url %q", p.Name, p.BaseURL)
+		}
+		if len(p.ModelPrefixes) == 0 && len(p.ModelPatterns) == 0 {
+			return fmt.Errorf("provider %q: at least one of model_prefixes or model_patterns is required", p.Name)
+		}
+		for _, pattern := range p.ModelPatterns {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return fmt.Errorf("provider %q: invalid model pattern %q: %w", p.Name, pattern, err)
+			}
+		}
+		if p.Timeout < 0 {
+			return fmt.Errorf("provider %q: timeout must not be negative", p.Name)
+		}
+	}
+	return nil
+}
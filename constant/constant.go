@@ -1,8 +1,58 @@
 package constant
 
 const (
-	GLMBaseURL = "https://open.bigmodel.cn/api/paas/v4/chat/completions"
-	GPTBaseURL = "https://api.openai.com/v1/chat/completions"
+	GLMBaseURL       = "https://open.bigmodel.cn/api/paas/v4/chat/completions"
+	GPTBaseURL       = "https://api.openai.com/v1/chat/completions"
+	AnthropicBaseURL = "https://api.anthropic.com/v1/messages"
+	OllamaBaseURL    = "http://localhost:11434" // 本地 Ollama 服务根地址
+)
+
+const (
+	AnthropicVersion          = "2023-06-01" // Messages API 版本头
+	AnthropicDefaultMaxTokens = 4096         // Messages API 要求必须指定 max_tokens
+)
+
+// 服务商协议类型
+const (
+	ProviderTypeOpenAI    = "openai"
+	ProviderTypeGLM       = "glm"
+	ProviderTypeAnthropic = "anthropic"
+	ProviderTypeOllama    = "ollama"
+)
+
+// 服务商鉴权方式
+const (
+	AuthBearer  = "bearer"    // Authorization: Bearer <key>
+	AuthAPIKey  = "api-key"   // api-key: <key>（Azure OpenAI）
+	AuthXAPIKey = "x-api-key" // x-api-key: <key>（Anthropic）
+	AuthNone    = "none"
+)
+
+// 上下文超出窗口时的截断策略
+const (
+	ContextDropOldest = "drop_oldest" // 丢弃最早的轮次
+	ContextLastN      = "last_n"      // 只保留最近 N 轮
+	ContextSummarize  = "summarize"   // 将丢弃的轮次压缩为摘要
+)
+
+// 费用报表的分组维度
+const (
+	ReportByDay   = "day"   // 按天（UTC）
+	ReportByUser  = "user"  // 按用户
+	ReportByModel = "model" // 按服务商与模型
+	ReportByKind  = "kind"  // 按用量记录类型
+)
+
+// 用量记录类型
+const (
+	UsageKindReply   = "reply"   // 生成回复
+	UsageKindSummary = "summary" // 上下文摘要与滚动摘要，不关联消息（message_id 为 0）
 )
 
 const SystemPrompt = "你是一个乐于回答各种问题的小助手"
+
+// SummaryPrompt 生成对话摘要的提示
+const SummaryPrompt = "请将以下对话压缩为简洁的摘要，保留关键事实、用户的偏好与要求、已得出的结论和尚未解决的问题，不要添加对话中没有的信息。"
+
+// SummaryPrefix 注入摘要时的前缀
+const SummaryPrefix = "以下是此前对话的摘要：\n"
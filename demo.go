@@ -1,17 +1,14 @@
 package main
 
 import (
-	"bufio"
-	"bytes"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 
 	"github.com/EthanGuo-coder/llm-backend-api/models"
-	"github.com/EthanGuo-coder/llm-backend-api/utils"
+	"github.com/EthanGuo-coder/llm-backend-api/providers"
 )
 
 func streamChat(c *gin.Context) {
@@ -33,44 +30,31 @@ func streamChat(c *gin.Context) {
 	}
 
 	// 构造请求的消息体
-	messages := []map[string]string{
-		{"role": "system", "content": "你是一个乐于回答各种问题的小助手"},
-		{"role": "user", "content": req.Query}, // 用户传入的问题作为内容
+	messages := []models.Message{
+		{Role: "system", Content: "你是一个乐于回答各种问题的小助手"},
+		{Role: "user", Content: req.Query}, // 用户传入的问题作为内容
 	}
 
-	requestBody := map[string]interface{}{
-		"model":    req.Model,
-		"messages": messages,
-		"stream":   true, // 开启SSE流式返回
-	}
-
-	// 将请求体转换为JSON格式
-	requestData, err := json.Marshal(requestBody)
+	// 匹配模型服务商
+	provider, err := providers.Resolve(req.Model)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to marshal request body"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	// 创建HTTP请求
-	client := &http.Client{}
-	baseURL, err := utils.GetBaseURL(req.Model)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err})
-		return
-	}
-
-	apiReq, err := http.NewRequest("POST", baseURL, bytes.NewBuffer(requestData))
+	// 创建HTTP请求（请求体与请求头由服务商构造）
+	apiReq, err := provider.BuildRequest(c.Request.Context(), &providers.ChatRequest{
+		Model:    req.Model,
+		ApiKey:   req.ApiKey,
+		Messages: messages,
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create request"})
 		return
 	}
 
-	// 设置请求头
-	apiReq.Header.Set("Content-Type", "application/json")
-	apiReq.Header.Set("Authorization", "Bearer "+req.ApiKey)
-
 	// 发送HTTP请求
-	resp, err := client.Do(apiReq)
+	resp, err := provider.HTTPClient().Do(apiReq)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send request"})
 		return
@@ -79,73 +63,51 @@ func streamChat(c *gin.Context) {
 
 	// 检查响应状态码
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		c.JSON(resp.StatusCode, gin.H{"error": "Unexpected response status", "details": string(body)})
+		c.JSON(resp.StatusCode, gin.H{"error": "Unexpected response status", "details": provider.MapError(resp).Error()})
 		return
 	}
 
-	// 解析SSE流数据并实时以JSON格式返回
-	reader := bufio.NewReader(resp.Body)
+	// 解析上游流数据并实时以JSON格式返回
 	var fullResponse string // 用于存储完整的返回信息
-	for {
-		line, err := reader.ReadBytes('\n')
-		if err != nil {
-			if err == io.EOF {
-				break
-			}
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reading stream", "details": err.Error()})
-			return
-		}
-
-		// 忽略注释行或空行
-		if len(line) == 0 || line[0] == ':' {
-			continue
+	err = provider.DecodeStream(resp.Body, func(chunk *providers.StreamChunk) error {
+		if chunk.Error != "" {
+			errorMessage, _ := json.Marshal(map[string]string{
+				"event": "error",
+				"data":  chunk.Error,
+			})
+			fmt.Fprintf(c.Writer, "%s\n\n", errorMessage)
+			c.Writer.Flush()
+			return nil
 		}
 
-		// 解析SSE数据行
-		if bytes.HasPrefix(line, []byte("data: ")) {
-			data := bytes.TrimPrefix(line, []byte("data: "))
-			data = bytes.TrimSpace(data)
-
-			// 检测结束标志
-			if string(data) == "[DONE]" {
-				// 发送结束事件
-				endMessage, _ := json.Marshal(map[string]string{
-					"event": "done",
-					"data":  "Stream finished",
-				})
-				fmt.Fprintf(c.Writer, "%s\n\n", endMessage)
-				c.Writer.Flush()
-
-				// 打印完整的返回信息
-				fmt.Fprintf(c.Writer, "{\"event\":\"full_response\",\"data\":\"%s\"}\n\n", fullResponse)
-				c.Writer.Flush()
-				break
-			}
-
-			// 使用结构体解析JSON格式数据
-			var sseResponse models.SSEResponse
-			if err := json.Unmarshal(data, &sseResponse); err != nil {
-				errorMessage, _ := json.Marshal(map[string]string{
-					"event": "error",
-					"data":  fmt.Sprintf("Failed to unmarshal SSE data: %v", err),
-				})
-				fmt.Fprintf(c.Writer, "%s\n\n", errorMessage)
-				c.Writer.Flush()
-				continue
-			}
-
-			// 将每个增量内容封装为JSON
-			for _, choice := range sseResponse.Choices {
-				content := choice.Delta.Content
-				fullResponse += content // 累加内容到完整响应
-				message, _ := json.Marshal(map[string]string{
-					"event": "message",
-					"data":  content,
-				})
-				fmt.Fprintf(c.Writer, "%s\n\n", message)
-				c.Writer.Flush() // 确保数据实时发送
-			}
-		}
+		// 将每个增量内容封装为JSON
+		fullResponse += chunk.Content // 累加内容到完整响应
+		message, _ := json.Marshal(map[string]string{
+			"event": "message",
+			"data":  chunk.Content,
+		})
+		fmt.Fprintf(c.Writer, "%s\n\n", message)
+		c.Writer.Flush() // 确保数据实时发送
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reading stream", "details": err.Error()})
+		return
 	}
+
+	// 发送结束事件
+	endMessage, _ := json.Marshal(map[string]string{
+		"event": "done",
+		"data":  "Stream finished",
+	})
+	fmt.Fprintf(c.Writer, "%s\n\n", endMessage)
+	c.Writer.Flush()
+
+	// 打印完整的返回信息
+	fullMessage, _ := json.Marshal(map[string]string{
+		"event": "full_response",
+		"data":  fullResponse,
+	})
+	fmt.Fprintf(c.Writer, "%s\n\n", fullMessage)
+	c.Writer.Flush()
 }
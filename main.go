@@ -9,8 +9,11 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"github.com/EthanGuo-coder/llm-backend-api/config"
+	"github.com/EthanGuo-coder/llm-backend-api/providers"
 	"github.com/EthanGuo-coder/llm-backend-api/routes"
+	"github.com/EthanGuo-coder/llm-backend-api/services"
 	"github.com/EthanGuo-coder/llm-backend-api/storage"
+	"github.com/EthanGuo-coder/llm-backend-api/tools"
 )
 
 func main() {
@@ -18,6 +21,17 @@ func main() {
 	if err := config.LoadConfig("."); err != nil {
 		log.Fatalf("Error loading config: %v", err)
 	}
+	// 注册模型服务商
+	if err := providers.InitializeProviders(); err != nil {
+		log.Fatalf("Error initializing providers: %v", err)
+	}
+	// 注册工具
+	if err := services.RegisterKnowledgeBaseTool(); err != nil {
+		log.Fatalf("Error registering knowledge base tool: %v", err)
+	}
+	if err := tools.InitializeTools(); err != nil {
+		log.Fatalf("Error initializing tools: %v", err)
+	}
 	// 初始化 Redis
 	if err := storage.InitializeRedis(); err != nil {
 		log.Fatalf("Error initializing Redis: %v", err)
@@ -26,16 +40,24 @@ func main() {
 	if err := storage.InitializeSQLite(); err != nil {
 		log.Fatalf("Error initializing SQLite: %v", err)
 	}
+	// 初始化文件存储
+	if err := storage.InitializeBlobStore(); err != nil {
+		log.Fatalf("Error initializing blob store: %v", err)
+	}
 
 	r := gin.Default()
 	r.RedirectTrailingSlash = true
+	// 只信任配置的反向代理转发的客户端 IP，避免伪造 X-Forwarded-For 绕过按 IP 限流
+	if err := r.SetTrustedProxies(config.AppConfig.Server.TrustedProxies); err != nil {
+		log.Fatalf("Error setting trusted proxies: %v", err)
+	}
 
 	// 配置 CORS 中间件
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"}, // 允许所有来源
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "Last-Event-ID"},
+		ExposeHeaders:    []string{"Content-Length", "X-Generation-ID", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
@@ -44,7 +66,7 @@ func main() {
 	routes.RegisterRoutes(r)
 
 	serverPort := config.AppConfig.Server.Port
-	fmt.Printf("Server is running on port %d\n", serverPort)
+	fmt.Printf("Server is running on port %s\n", serverPort)
 	// 启动服务器
 	r.Run(serverPort) // 启动在8080端口
 }
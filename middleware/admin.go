@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/EthanGuo-coder/llm-backend-api/billing"
+	"github.com/EthanGuo-coder/llm-backend-api/utils"
+)
+
+// AdminMiddleware 管理员权限中间件，需在 AuthMiddleware 之后使用，管理员由 billing.admins 配置
+func AdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !billing.IsAdmin(utils.GetUserIDFromContext(c)) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Admin privileges required"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/EthanGuo-coder/llm-backend-api/services"
+	"github.com/EthanGuo-coder/llm-backend-api/utils"
+)
+
+// QuotaMiddleware 用户配额中间件，需在 AuthMiddleware 之后使用。
+// 放行的请求计入请求数，处理结果为 4xx（参数错误、会话不存在或忙等）时退回；
+// 超出配额时返回 429、超额详情与重置时间
+func QuotaMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := utils.GetUserIDFromContext(c)
+		exceeded, refund, err := services.CheckQuota(userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check quota"})
+			c.Abort()
+			return
+		}
+		if exceeded != nil {
+			retryAfter := max(exceeded.ResetAt-time.Now().Unix(), 1)
+			c.Header("Retry-After", strconv.FormatInt(retryAfter, 10))
+			c.JSON(http.StatusTooManyRequests, exceeded)
+			c.Abort()
+			return
+		}
+		c.Next()
+		// 请求在开始生成前被拒绝，不占用请求数
+		if status := c.Writer.Status(); status >= http.StatusBadRequest && status < http.StatusInternalServerError {
+			refund()
+		}
+	}
+}
@@ -2,7 +2,8 @@ package models
 
 type Config struct {
 	Server struct {
-		Port string `mapstructure:"port"`
+		Port           string   `mapstructure:"port"`
+		TrustedProxies []string `mapstructure:"trusted_proxies"` // 可信反向代理，只有来自这些地址的 X-Forwarded-For 才用于识别客户端 IP
 	} `mapstructure:"server"`
 
 	Redis struct {
@@ -25,4 +26,102 @@ type Config struct {
 	RAG struct {
 		ServiceAddr string `mapstructure:"service_addr"`
 	} `mapstructure:"rag"`
+
+	Providers []ProviderConfig `mapstructure:"providers"`
+
+	Fallbacks []FallbackConfig `mapstructure:"fallbacks"`
+
+	Retry struct {
+		MaxAttempts    int     `mapstructure:"max_attempts"`    // 每个模型的最大尝试次数（含首次）
+		InitialBackoff int     `mapstructure:"initial_backoff"` // 首次重试前的等待，毫秒
+		MaxBackoff     int     `mapstructure:"max_backoff"`     // 单次等待上限，毫秒
+		Multiplier     float64 `mapstructure:"multiplier"`      // 退避倍数
+		MaxRetryAfter  int     `mapstructure:"max_retry_after"` // 可接受的 Retry-After 上限，秒，超过则不再重试
+	} `mapstructure:"retry"`
+
+	Stream struct {
+		TTL         int `mapstructure:"ttl"`          // 生成事件流在 Redis 中保留的时间，秒
+		IdleTimeout int `mapstructure:"idle_timeout"` // 续传时等待新事件的最长时间，秒
+	} `mapstructure:"stream"`
+
+	Context struct {
+		Strategy         string               `mapstructure:"strategy"`           // 超出上下文窗口时的处理方式：drop_oldest | last_n | summarize
+		KeepTurns        int                  `mapstructure:"keep_turns"`         // last_n 保留的最近轮数
+		ReserveTokens    int                  `mapstructure:"reserve_tokens"`     // 为回复预留的 token 数，请求设置了 max_tokens 时以其为准
+		SummaryMaxTokens int                  `mapstructure:"summary_max_tokens"` // summarize 生成摘要的最大长度
+		Limits           []ContextLimitConfig `mapstructure:"limits"`             // 覆盖内置的模型上下文窗口
+	} `mapstructure:"context"`
+
+	Memory struct {
+		Enabled         bool   `mapstructure:"enabled"`          // 是否在对话变长后自动生成滚动摘要
+		Model           string `mapstructure:"model"`            // 生成摘要的模型，留空时使用会话模型
+		ThresholdTokens int    `mapstructure:"threshold_tokens"` // 未摘要的历史超过该 token 数时触发摘要
+		KeepTurns       int    `mapstructure:"keep_turns"`       // 保留原文、不参与摘要的最近轮数
+		MaxTokens       int    `mapstructure:"max_tokens"`       // 摘要的最大长度
+	} `mapstructure:"memory"`
+
+	Files struct {
+		Dir     string `mapstructure:"dir"`      // 本地 Blob 存储目录
+		MaxSize int64  `mapstructure:"max_size"` // 单个文件大小上限，MB
+	} `mapstructure:"files"`
+
+	Tools struct {
+		Enabled   []string `mapstructure:"enabled"`    // 启用的内置工具，为空时不向模型声明任何工具
+		MaxRounds int      `mapstructure:"max_rounds"` // 单条消息最多进行的工具调用轮数
+		Timeout   int      `mapstructure:"timeout"`    // 单次工具执行超时，秒
+	} `mapstructure:"tools"`
+
+	RateLimit struct {
+		Enabled bool                       `mapstructure:"enabled"`
+		Groups  map[string]RateLimitConfig `mapstructure:"groups"` // 按路由组配置，未配置的组使用 default
+	} `mapstructure:"rate_limit"`
+
+	Quota struct {
+		Enabled   bool                     `mapstructure:"enabled"`
+		UserQuota `mapstructure:",squash"` // 默认配额，可由管理员按用户覆盖
+	} `mapstructure:"quota"`
+
+	Billing struct {
+		Prices []PriceConfig `mapstructure:"prices"` // 模型价格表
+		Admins []int64       `mapstructure:"admins"` // 可查看全部用户费用报表的用户 ID
+	} `mapstructure:"billing"`
+}
+
+// ProviderConfig 模型服务商配置
+type ProviderConfig struct {
+	Name          string            `mapstructure:"name"`
+	Type          string            `mapstructure:"type"` // 协议类型：openai | glm | anthropic | ollama
+	BaseURL       string            `mapstructure:"base_url"`
+	ModelPrefixes []string          `mapstructure:"model_prefixes"` // 模型名前缀，不区分大小写
+	ModelPatterns []string          `mapstructure:"model_patterns"` // 模型名正则
+	Headers       map[string]string `mapstructure:"headers"`        // 额外请求头
+	Timeout       int               `mapstructure:"timeout"`        // 等待上游响应头的超时，秒，0 表示不限
+	Auth          string            `mapstructure:"auth"`           // 鉴权方式：bearer | api-key | x-api-key | none，留空按协议默认
+	APIKey        string            `mapstructure:"api_key"`        // 服务端密钥，请求未携带密钥（如降级到该服务商）时使用
+}
+
+// ContextLimitConfig 模型上下文窗口配置
+type ContextLimitConfig struct {
+	ModelPrefix   string `mapstructure:"model_prefix"` // 模型名前缀，不区分大小写
+	ContextWindow int    `mapstructure:"context_window"`
+}
+
+// RateLimitConfig 路由组限流配置，滑动窗口内最多 Limit 次请求
+type RateLimitConfig struct {
+	Limit  int `mapstructure:"limit"`
+	Window int `mapstructure:"window"` // 窗口长度，秒
+}
+
+// FallbackConfig 模型降级链配置
+type FallbackConfig struct {
+	Model string   `mapstructure:"model"`
+	Chain []string `mapstructure:"chain"` // 按顺序尝试的降级模型
+}
+
+// PriceConfig 模型价格，单位为美元 / 百万 token
+type PriceConfig struct {
+	Provider    string  `mapstructure:"provider"`     // 服务商名称，留空匹配任意服务商
+	ModelPrefix string  `mapstructure:"model_prefix"` // 模型名前缀，不区分大小写，留空匹配该服务商的全部模型
+	Input       float64 `mapstructure:"input"`        // 输入（prompt）token 单价
+	Output      float64 `mapstructure:"output"`       // 输出（completion）token 单价
 }
@@ -0,0 +1,34 @@
+package models
+
+// 消息片段类型
+const (
+	PartText     = "text"
+	PartImageURL = "image_url"
+	PartFile     = "file"
+)
+
+// ContentPart 多模态消息片段，格式与 OpenAI content parts 一致，按 Type 使用不同字段
+type ContentPart struct {
+	Type     string    `json:"type"` // text | image_url | file
+	Text     string    `json:"text,omitempty"`
+	ImageURL *ImageURL `json:"image_url,omitempty"`
+	File     *FileRef  `json:"file,omitempty"`
+}
+
+// ImageURL 图片片段。请求中可传 http(s) 地址、data:<mime>;base64 内联图片或已上传图片的 file_id，
+// 内联图片会先转存为文件，会话中只保存 file_id
+type ImageURL struct {
+	URL          string `json:"url,omitempty"`
+	FileID       string `json:"file_id,omitempty"`
+	Detail       string `json:"detail,omitempty"`        // low | high | auto，仅 OpenAI 使用
+	ThumbnailURL string `json:"thumbnail_url,omitempty"` // 仅在历史记录中返回
+}
+
+// FileRef 已上传文件的引用
+type FileRef struct {
+	FileID   string `json:"file_id"`
+	Filename string `json:"filename,omitempty"`
+	URL      string `json:"url,omitempty"` // 仅在历史记录中返回
+	// Data 发送给上游前填充的 data URL，不保存
+	Data string `json:"-"`
+}
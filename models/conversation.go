@@ -1,31 +1,81 @@
 package models
 
 type Message struct {
-	Role      string `json:"role"`
-	Content   string `json:"content"`
-	MessageID int32  `json:"message_id"`
+	Role    string `json:"role"`
+	Content string `json:"content"` // 纯文本内容；多模态消息为其中文本片段的拼接
+	// Parts 多模态消息片段，纯文本消息为空
+	Parts     []ContentPart `json:"parts,omitempty"`
+	MessageID int32         `json:"message_id"`
+	Model     string        `json:"model,omitempty"` // 实际生成该回复的模型（发生降级时与会话模型不同）
+	// Interrupted 生成中途因客户端断开或上游错误而中断，Content 为已收到的部分内容
+	Interrupted bool `json:"interrupted,omitempty"`
+	// Stopped 生成被停止接口中止，Content 为停止前收到的内容
+	Stopped bool `json:"stopped,omitempty"`
+	// ToolCalls assistant 消息中模型发起的工具调用
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	// ToolCallID、Name role 为 tool 的消息对应的调用 ID 与工具名
+	ToolCallID string `json:"tool_call_id,omitempty"`
+	Name       string `json:"name,omitempty"`
+	// Usage assistant 回复的 token 用量（含同一条消息内各轮工具调用），上游未报告时为空
+	Usage *Usage `json:"usage,omitempty"`
+	// Cost 按价格表计算的回复费用（美元），未配置价格时为 0
+	Cost float64 `json:"cost_usd,omitempty"`
+	// LatencyMs、TTFTMs 从开始请求上游到生成结束、到收到首个 token 的耗时，毫秒
+	LatencyMs int64 `json:"latency_ms,omitempty"`
+	TTFTMs    int64 `json:"ttft_ms,omitempty"`
+	// ParentID 父消息 ID，会话以首条系统提示为根组成消息树；编辑或重新生成会在父消息下产生新的分支
+	ParentID int32 `json:"parent_id"`
+	// Branches 同一父消息下全部分支的首条消息 ID（按创建顺序，含自身），只有一个分支时为空；仅在会话历史中返回
+	Branches []int32 `json:"branches,omitempty"`
 }
 
 type Conversation struct {
-	ID          int64     `json:"conversation_id"`
-	Title       string    `json:"title"`
-	Model       string    `json:"model"`
-	ApiKey      string    `json:"api_key"`
-	Messages    []Message `json:"messages"`
-	CreatedTime int64     `json:"created_time"` // Unix 时间戳
+	ID          int64             `json:"conversation_id"`
+	Title       string            `json:"title"`
+	Model       string            `json:"model"`
+	ApiKey      string            `json:"api_key"`
+	Params      *GenerationParams `json:"params,omitempty"` // 会话默认生成参数
+	KBIDs       []string          `json:"kb_ids,omitempty"` // 关联的知识库，模型可通过 search_knowledge_base 工具检索
+	Messages    []Message         `json:"-"`                // 当前选中的分支：从根消息到 LeafID 的路径，只有它会发送给模型
+	Tree        []Message         `json:"messages"`         // 全部分支的消息，保存时由 Messages 合并而来
+	LeafID      int32             `json:"leaf_id"`          // 选中分支的最后一条消息
+	Schema      int               `json:"schema,omitempty"` // 存储格式版本，旧版线性会话为 0
+	ForkedFrom  *ForkSource       `json:"forked_from,omitempty"`
+	CreatedTime int64             `json:"created_time"` // Unix 时间戳
+	UserID      int64             `json:"-"`            // 会话所属用户，加载时设置，用于记录摘要等附带调用的用量
+	LockToken   string            `json:"-"`            // 持有的会话写锁令牌，加载时设置，保存以锁仍由该令牌持有为条件
+}
+
+// ForkSource 分叉会话的来源：源会话及复制到的最后一条消息
+type ForkSource struct {
+	ConversationID int64 `json:"conversation_id"`
+	MessageID      int32 `json:"message_id"`
+}
+
+// ConversationMemory 会话的滚动摘要。发送给模型时注入在系统提示之后，
+// 替代 ThroughMessageID 及之前的消息；存储中的完整历史不变
+type ConversationMemory struct {
+	Summary          string `json:"summary"`
+	ThroughMessageID int32  `json:"through_message_id"` // 摘要覆盖的最后一条消息
+	Model            string `json:"model"`              // 生成摘要的模型
+	UpdatedTime      int64  `json:"updated_time"`       // Unix 时间戳
 }
 
 type ConversationSummary struct {
-	ID          int64  `json:"conversation_id"`
-	Title       string `json:"title"`
-	CreatedTime int64  `json:"created_time"`
+	ID          int64       `json:"conversation_id"`
+	Title       string      `json:"title"`
+	ForkedFrom  *ForkSource `json:"forked_from,omitempty"`
+	CreatedTime int64       `json:"created_time"`
 }
 
 type ConversationHistory struct {
-	ID       int64     `json:"conversation_id"`
-	Title    string    `json:"title"`
-	Model    string    `json:"model"`
-	Messages []Message `json:"messages"`
+	ID         int64             `json:"conversation_id"`
+	Title      string            `json:"title"`
+	Model      string            `json:"model"`
+	Params     *GenerationParams `json:"params,omitempty"`
+	KBIDs      []string          `json:"kb_ids,omitempty"`
+	ForkedFrom *ForkSource       `json:"forked_from,omitempty"`
+	Messages   []Message         `json:"messages"`
 }
 
 type ConversationReq struct {
@@ -34,15 +84,46 @@ type ConversationReq struct {
 }
 
 type CreateConversationReq struct {
-	Model  string `json:"model" binding:"required"`
-	Title  string `json:"title" binding:"required"`
-	ApiKey string `json:"api_key" binding:"required"`
+	Model  string            `json:"model" binding:"required"`
+	Title  string            `json:"title" binding:"required"`
+	ApiKey string            `json:"api_key"` // 本地模型（如 Ollama）可留空
+	Params *GenerationParams `json:"params"`  // 会话默认生成参数，可选
+	KBIDs  []string          `json:"kb_ids"`  // 关联的知识库，可选
 }
 
 type CreateConversationResp struct {
-	ID          int64  `json:"conversation_id"`
-	Title       string `json:"title"`
-	Model       string `json:"model"`
-	ApiKey      string `json:"api_key"`
-	CreatedTime int64  `json:"created_time"` // Unix 时间戳
+	ID          int64             `json:"conversation_id"`
+	Title       string            `json:"title"`
+	Model       string            `json:"model"`
+	ApiKey      string            `json:"api_key,omitempty"` // 分叉会话时不返回
+	Params      *GenerationParams `json:"params,omitempty"`
+	KBIDs       []string          `json:"kb_ids,omitempty"`
+	ForkedFrom  *ForkSource       `json:"forked_from,omitempty"`
+	CreatedTime int64             `json:"created_time"` // Unix 时间戳
+}
+
+// ForkConversationReq 分叉会话请求。复制源会话中从首条消息到 MessageID 的分支，
+// 其余字段为空时沿用源会话的设置
+type ForkConversationReq struct {
+	MessageID *int32            `json:"message_id" binding:"required"`
+	Title     string            `json:"title"`   // 默认为源会话标题加 " (fork)"
+	Model     string            `json:"model"`   // 更换模型时参数按新模型重新校验
+	ApiKey    *string           `json:"api_key"` // 更换到其他服务商的模型时不沿用源会话的密钥，需一并提供
+	Params    *GenerationParams `json:"params"`
+	KBIDs     *[]string         `json:"kb_ids"` // 传空数组取消关联
+}
+
+// RegenerateReq 重新生成回复请求
+type RegenerateReq struct {
+	Params *GenerationParams `json:"params"` // 仅对本次生成有效，覆盖会话默认参数
+}
+
+// SwitchBranchReq 切换分支请求，选中包含 MessageID 的分支；MessageID 有后续消息时沿最新的分支走到末尾
+type SwitchBranchReq struct {
+	MessageID *int32 `json:"message_id" binding:"required"`
+}
+
+// UpdateKnowledgeBasesReq 更新会话关联知识库请求，为空时取消关联
+type UpdateKnowledgeBasesReq struct {
+	KBIDs []string `json:"kb_ids"`
 }
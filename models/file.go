@@ -0,0 +1,19 @@
+package models
+
+// File 上传文件的元信息，内容保存在 Blob 存储中
+type File struct {
+	ID           string `json:"file_id"`
+	UserID       int64  `json:"-"`
+	Filename     string `json:"filename"`
+	MimeType     string `json:"mime_type"`
+	Size         int64  `json:"size"`
+	HasThumbnail bool   `json:"-"`
+	CreatedTime  int64  `json:"created_time"` // Unix 时间戳
+}
+
+// UploadFileResp 上传文件响应
+type UploadFileResp struct {
+	File
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnail_url,omitempty"`
+}
@@ -0,0 +1,17 @@
+package models
+
+// GenerationParams 生成参数，未设置的字段不会发送给上游，由服务商使用默认值
+type GenerationParams struct {
+	Temperature      *float64 `json:"temperature,omitempty"`
+	TopP             *float64 `json:"top_p,omitempty"`
+	MaxTokens        *int     `json:"max_tokens,omitempty"`
+	Stop             []string `json:"stop,omitempty"`
+	Seed             *int64   `json:"seed,omitempty"`
+	PresencePenalty  *float64 `json:"presence_penalty,omitempty"`
+	FrequencyPenalty *float64 `json:"frequency_penalty,omitempty"`
+}
+
+// UpdateParamsReq 更新会话默认生成参数请求
+type UpdateParamsReq struct {
+	Params *GenerationParams `json:"params"`
+}
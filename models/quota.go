@@ -0,0 +1,47 @@
+package models
+
+// QuotaLimits 一个周期内的限额，0 表示不限
+type QuotaLimits struct {
+	Tokens   int64   `json:"tokens" mapstructure:"tokens"`
+	Requests int64   `json:"requests" mapstructure:"requests"`
+	Cost     float64 `json:"cost_usd" mapstructure:"cost_usd"` // 美元
+}
+
+// UserQuota 用户的日配额与月配额，周期按 UTC 自然日、自然月计算
+type UserQuota struct {
+	Daily   QuotaLimits `json:"daily" mapstructure:"daily"`
+	Monthly QuotaLimits `json:"monthly" mapstructure:"monthly"`
+}
+
+// QuotaUsage 一个周期内已用的额度
+type QuotaUsage struct {
+	Tokens   int64   `json:"tokens"`
+	Requests int64   `json:"requests"`
+	Cost     float64 `json:"cost_usd"`
+}
+
+// QuotaPeriodStatus 一个周期的限额、已用额度与重置时间
+type QuotaPeriodStatus struct {
+	Limits  QuotaLimits `json:"limits"`
+	Used    QuotaUsage  `json:"used"`
+	ResetAt int64       `json:"reset_at"` // Unix 时间戳
+}
+
+// QuotaStatus 用户当前的配额状态
+type QuotaStatus struct {
+	UserID   int64             `json:"user_id"`
+	Override bool              `json:"override"` // 是否为管理员单独设置的配额
+	Daily    QuotaPeriodStatus `json:"daily"`
+	Monthly  QuotaPeriodStatus `json:"monthly"`
+}
+
+// QuotaExceeded 超出配额的详情，作为 429 响应体返回
+type QuotaExceeded struct {
+	Error   string  `json:"error"`
+	Code    string  `json:"code"`   // 固定为 quota_exceeded
+	Period  string  `json:"period"` // daily | monthly
+	Metric  string  `json:"metric"` // tokens | requests | cost_usd
+	Limit   float64 `json:"limit"`
+	Used    float64 `json:"used"`
+	ResetAt int64   `json:"reset_at"` // 配额重置的 Unix 时间戳
+}
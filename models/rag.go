@@ -92,8 +92,9 @@ type ListEmbeddingModelsResponse struct {
 
 // RagChatRequest 基于知识库的对话请求
 type RagChatRequest struct {
-	ConversationID int64  `json:"conversation_id" binding:"required"`
-	KBID           string `json:"kb_id" binding:"required"`
-	Message        string `json:"message" binding:"required"`
-	TopK           int    `json:"top_k,omitempty"`
+	ConversationID int64             `json:"conversation_id" binding:"required"`
+	KBID           string            `json:"kb_id" binding:"required"`
+	Message        string            `json:"message" binding:"required"`
+	TopK           int               `json:"top_k,omitempty"`
+	Params         *GenerationParams `json:"params"` // 仅对本条消息生效，覆盖会话默认参数
 }
@@ -1,18 +1,59 @@
 package models
 
+import "encoding/json"
+
 // SSEDelta 定义结构体以匹配 JSON 数据格式
 type SSEDelta struct {
-	Content string `json:"content"`
+	Content   string             `json:"content"`
+	ToolCalls []SSEToolCallDelta `json:"tool_calls"`
+}
+
+// SSEToolCallDelta 流式工具调用片段，同一 index 的 arguments 需按顺序拼接
+type SSEToolCallDelta struct {
+	Index    int    `json:"index"`
+	ID       string `json:"id"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
 }
 
 type SSEChoice struct {
-	Delta SSEDelta `json:"delta"`
+	Delta        SSEDelta `json:"delta"`
+	FinishReason string   `json:"finish_reason"`
 }
 
 type SSEResponse struct {
 	Choices []SSEChoice `json:"choices"`
+	Usage   *Usage      `json:"usage"`
+}
+
+// Usage 一次生成的 token 用量
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// ChatResponse 非流式对话响应
+type ChatResponse struct {
+	ConversationID int64   `json:"conversation_id"`
+	MessageID      int32   `json:"message_id"`
+	Message        Message `json:"message"`
+	FinishReason   string  `json:"finish_reason"`
+	Usage          *Usage  `json:"usage"`
+}
+
+// StreamEvent 生成过程中推送给客户端的单个事件，ID 为 Redis Stream 条目 ID
+type StreamEvent struct {
+	ID    string          `json:"id"`
+	Event string          `json:"event"`
+	Data  json.RawMessage `json:"data"`
 }
 
+// AskReq 发送消息请求，message 与 parts 至少提供一个
 type AskReq struct {
-	Message string `json:"message" binding:"required"`
+	Message string            `json:"message"`
+	Parts   []ContentPart     `json:"parts"`  // 多模态片段，message 非空时作为首个文本片段
+	Params  *GenerationParams `json:"params"` // 仅对本条消息生效，覆盖会话默认参数
 }
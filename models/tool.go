@@ -0,0 +1,25 @@
+package models
+
+import "encoding/json"
+
+// ToolDefinition 向模型声明的工具，Parameters 为参数的 JSON Schema
+type ToolDefinition struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Parameters  json.RawMessage `json:"parameters"`
+}
+
+// ToolCall 模型发起的一次工具调用，Arguments 为 JSON 编码的参数
+type ToolCall struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// ToolResult 工具执行结果，通过 SSE tool_result 事件推送
+type ToolResult struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Content string `json:"content"`
+	IsError bool   `json:"is_error,omitempty"`
+}
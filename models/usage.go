@@ -0,0 +1,73 @@
+package models
+
+// UsageRecord 单次回复或摘要调用的用量记录
+type UsageRecord struct {
+	UserID           int64
+	ConversationID   int64
+	MessageID        int32
+	Provider         string // 模型所属服务商
+	Model            string // 实际生成回复的模型
+	PromptTokens     int
+	CompletionTokens int
+	TotalTokens      int
+	LatencyMs        int64
+	TTFTMs           int64
+	Cost             float64 // 美元，模型未配置价格时为 0
+	CreatedTime      int64   // Unix 时间戳
+	Kind             string  // 记录类型，见 constant.UsageKind*
+}
+
+// UsageSummary 汇总的用量，请求数与平均耗时只统计回复，摘要调用只计入 token 与费用
+type UsageSummary struct {
+	Requests         int64   `json:"requests"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	TotalTokens      int64   `json:"total_tokens"`
+	Cost             float64 `json:"cost_usd"`
+	AvgLatencyMs     int64   `json:"avg_latency_ms"`
+	AvgTTFTMs        int64   `json:"avg_ttft_ms"`
+}
+
+// ConversationUsage 单个会话的用量
+type ConversationUsage struct {
+	ConversationID int64 `json:"conversation_id"`
+	UsageSummary
+}
+
+// UserUsageResp 用户用量，包含总计与按会话的明细
+type UserUsageResp struct {
+	Total         UsageSummary        `json:"total"`
+	Conversations []ConversationUsage `json:"conversations"`
+}
+
+// ReportQuery 费用报表查询条件
+type ReportQuery struct {
+	GroupBy []string // 分组维度：day | user | model | kind
+	UserID  int64    // 只统计该用户，0 表示全部用户
+	From    int64    // 起始时间（含），Unix 时间戳
+	To      int64    // 结束时间（不含），Unix 时间戳
+}
+
+// ReportRow 费用报表的一行，未参与分组的维度为空
+type ReportRow struct {
+	Day              string  `json:"day,omitempty"`
+	UserID           int64   `json:"user_id,omitempty"`
+	Username         string  `json:"username,omitempty"`
+	Provider         string  `json:"provider,omitempty"`
+	Model            string  `json:"model,omitempty"`
+	Kind             string  `json:"kind,omitempty"`
+	Requests         int64   `json:"requests"` // 回复数，摘要调用不计入
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	TotalTokens      int64   `json:"total_tokens"`
+	Cost             float64 `json:"cost_usd"`
+}
+
+// ReportResp 费用报表
+type ReportResp struct {
+	From    string      `json:"from"` // 起始日期（含），UTC
+	To      string      `json:"to"`   // 结束日期（含），UTC
+	GroupBy []string    `json:"group_by"`
+	Rows    []ReportRow `json:"rows"`
+	Total   ReportRow   `json:"total"`
+}
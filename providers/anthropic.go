@@ -0,0 +1,310 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/EthanGuo-coder/llm-backend-api/constant"
+	"github.com/EthanGuo-coder/llm-backend-api/models"
+)
+
+// AnthropicProvider Anthropic Messages API 服务商
+type AnthropicProvider struct {
+	base
+}
+
+// NewAnthropicProvider 创建 Anthropic 服务商
+func NewAnthropicProvider(opts Options) *AnthropicProvider {
+	return &AnthropicProvider{newBase(opts)}
+}
+
+// anthropicMessage Messages API 中的单条消息，内容为内容块列表
+type anthropicMessage struct {
+	Role    string           `json:"role"`
+	Content []anthropicBlock `json:"content"`
+}
+
+// anthropicBlock 内容块，按 type 使用不同字段：text、tool_use 或 tool_result
+type anthropicBlock struct {
+	Type      string           `json:"type"`
+	Text      string           `json:"text,omitempty"`
+	ID        string           `json:"id,omitempty"`
+	Name      string           `json:"name,omitempty"`
+	Input     json.RawMessage  `json:"input,omitempty"`
+	ToolUseID string           `json:"tool_use_id,omitempty"`
+	Content   string           `json:"content,omitempty"`
+	Source    *anthropicSource `json:"source,omitempty"`
+}
+
+// anthropicSource image 与 document 块的数据来源：base64 或 url
+type anthropicSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+// anthropicTool 请求中声明的工具
+type anthropicTool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+// anthropicUsage Messages API 的 token 用量
+type anthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// anthropicEvent Messages API 流式事件，按 type 使用不同字段
+type anthropicEvent struct {
+	Type    string `json:"type"`
+	Message struct {
+		Usage anthropicUsage `json:"usage"`
+	} `json:"message"`
+	Usage        anthropicUsage `json:"usage"`
+	Index        int            `json:"index"`
+	ContentBlock struct {
+		Type string `json:"type"`
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"content_block"`
+	Delta struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		PartialJSON string `json:"partial_json"`
+		StopReason  string `json:"stop_reason"`
+	} `json:"delta"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// anthropicStopReasons 将 stop_reason 映射为 OpenAI 风格的 finish_reason
+var anthropicStopReasons = map[string]string{
+	"end_turn":      "stop",
+	"stop_sequence": "stop",
+	"max_tokens":    "length",
+	"tool_use":      "tool_calls",
+}
+
+// ValidateParams Messages API 的 temperature 范围为 [0, 1]，且不支持 seed 与 penalty
+func (p *AnthropicProvider) ValidateParams(params *models.GenerationParams) error {
+	if params == nil {
+		return nil
+	}
+	return firstError(
+		checkRange("temperature", params.Temperature, 0, 1),
+		checkRange("top_p", params.TopP, 0, 1),
+		checkMaxTokens(params),
+		checkUnsupported(p.Name(), params),
+	)
+}
+
+// BuildRequest 构造 Messages API 流式请求
+func (p *AnthropicProvider) BuildRequest(ctx context.Context, req *ChatRequest) (*http.Request, error) {
+	system, messages := toAnthropicMessages(req.Messages)
+
+	requestBody := map[string]interface{}{
+		"model":      req.Model,
+		"messages":   messages,
+		"max_tokens": constant.AnthropicDefaultMaxTokens,
+		"stream":     true,
+	}
+	if system != "" {
+		requestBody["system"] = system
+	}
+	if len(req.Tools) > 0 {
+		tools := make([]anthropicTool, 0, len(req.Tools))
+		for _, definition := range req.Tools {
+			tools = append(tools, anthropicTool{Name: definition.Name, Description: definition.Description, InputSchema: definition.Parameters})
+		}
+		requestBody["tools"] = tools
+	}
+	if params := req.Params; params != nil {
+		if params.MaxTokens != nil {
+			requestBody["max_tokens"] = *params.MaxTokens
+		}
+		if params.Temperature != nil {
+			requestBody["temperature"] = *params.Temperature
+		}
+		if params.TopP != nil {
+			requestBody["top_p"] = *params.TopP
+		}
+		if len(params.Stop) > 0 {
+			requestBody["stop_sequences"] = params.Stop
+		}
+	}
+	requestData, err := json.Marshal(requestBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request body: %w", err)
+	}
+
+	apiReq, err := http.NewRequestWithContext(ctx, "POST", p.opts.BaseURL, bytes.NewBuffer(requestData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	apiReq.Header.Set("anthropic-version", constant.AnthropicVersion)
+	p.setHeaders(apiReq, req.ApiKey)
+	return apiReq, nil
+}
+
+// toAnthropicMessages 拆出系统提示，并保证 user/assistant 轮次交替且以 user 开头。
+// 工具调用转换为 tool_use 块，工具结果转换为 user 轮次中的 tool_result 块。
+func toAnthropicMessages(history []models.Message) (string, []anthropicMessage) {
+	var systemParts []string
+	messages := make([]anthropicMessage, 0, len(history))
+
+	for _, message := range history {
+		if message.Role == "system" {
+			systemParts = append(systemParts, message.Content)
+			continue
+		}
+		// Messages API 要求首条为 user 消息
+		if len(messages) == 0 && message.Role != "user" {
+			continue
+		}
+
+		role, blocks := message.Role, toAnthropicBlocks(message)
+		if role == "tool" {
+			role = "user"
+		}
+		if len(blocks) == 0 {
+			continue
+		}
+		// 相邻的同角色消息合并为一轮
+		if last := len(messages) - 1; last >= 0 && messages[last].Role == role {
+			messages[last].Content = append(messages[last].Content, blocks...)
+			continue
+		}
+		messages = append(messages, anthropicMessage{Role: role, Content: blocks})
+	}
+
+	return strings.Join(systemParts, "\n\n"), messages
+}
+
+// toAnthropicBlocks 将单条消息转换为内容块，空文本不生成块
+func toAnthropicBlocks(message models.Message) []anthropicBlock {
+	if message.Role == "tool" {
+		return []anthropicBlock{{Type: "tool_result", ToolUseID: message.ToolCallID, Content: message.Content}}
+	}
+
+	var blocks []anthropicBlock
+	if len(message.Parts) > 0 {
+		blocks = toAnthropicPartBlocks(message.Parts)
+	} else if message.Content != "" {
+		blocks = append(blocks, anthropicBlock{Type: "text", Text: message.Content})
+	}
+	for _, call := range message.ToolCalls {
+		input := json.RawMessage(call.Arguments)
+		if !json.Valid(input) {
+			input = json.RawMessage("{}")
+		}
+		blocks = append(blocks, anthropicBlock{Type: "tool_use", ID: call.ID, Name: call.Name, Input: input})
+	}
+	return blocks
+}
+
+// toAnthropicPartBlocks 转换多模态片段：图片转换为 image 块，PDF 转换为 document 块，
+// 其他无法识别的文件以文件名占位
+func toAnthropicPartBlocks(parts []models.ContentPart) []anthropicBlock {
+	var blocks []anthropicBlock
+	for _, part := range parts {
+		switch {
+		case part.Type == models.PartText && part.Text != "":
+			blocks = append(blocks, anthropicBlock{Type: "text", Text: part.Text})
+		case part.Type == models.PartImageURL && part.ImageURL != nil && part.ImageURL.URL != "":
+			source := &anthropicSource{Type: "url", URL: part.ImageURL.URL}
+			if mediaType, data, ok := parseDataURL(part.ImageURL.URL); ok {
+				source = &anthropicSource{Type: "base64", MediaType: mediaType, Data: data}
+			}
+			blocks = append(blocks, anthropicBlock{Type: "image", Source: source})
+		case part.Type == models.PartFile && part.File != nil:
+			if mediaType, data, ok := parseDataURL(part.File.Data); ok && mediaType == "application/pdf" {
+				blocks = append(blocks, anthropicBlock{Type: "document", Source: &anthropicSource{Type: "base64", MediaType: mediaType, Data: data}})
+			} else {
+				blocks = append(blocks, anthropicBlock{Type: "text", Text: fmt.Sprintf("[file: %s]", part.File.Filename)})
+			}
+		}
+	}
+	return blocks
+}
+
+// DecodeStream 将 message_start / content_block_delta / message_stop 等事件转换为统一增量
+func (p *AnthropicProvider) DecodeStream(body io.Reader, onChunk func(chunk *StreamChunk) error) error {
+	// 输入 token 数在 message_start 中给出，输出 token 数在 message_delta 中给出
+	var inputTokens int
+	return readSSE(body, func(_ string, data []byte) error {
+		var event anthropicEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			return onChunk(&StreamChunk{Error: fmt.Sprintf("Failed to unmarshal SSE data: %v", err)})
+		}
+
+		switch event.Type {
+		case "message_start":
+			inputTokens = event.Message.Usage.InputTokens
+			return nil
+		case "content_block_start":
+			if event.ContentBlock.Type != "tool_use" {
+				return nil
+			}
+			return onChunk(&StreamChunk{ToolCalls: []ToolCallDelta{{
+				Index: event.Index,
+				ID:    event.ContentBlock.ID,
+				Name:  event.ContentBlock.Name,
+			}}})
+		case "content_block_delta":
+			switch event.Delta.Type {
+			case "text_delta":
+				return onChunk(&StreamChunk{Content: event.Delta.Text})
+			case "input_json_delta":
+				return onChunk(&StreamChunk{ToolCalls: []ToolCallDelta{{Index: event.Index, Arguments: event.Delta.PartialJSON}}})
+			}
+			return nil
+		case "message_delta":
+			chunk := &StreamChunk{Usage: &models.Usage{
+				PromptTokens:     inputTokens,
+				CompletionTokens: event.Usage.OutputTokens,
+				TotalTokens:      inputTokens + event.Usage.OutputTokens,
+			}}
+			if event.Delta.StopReason != "" {
+				chunk.FinishReason = anthropicFinishReason(event.Delta.StopReason)
+			}
+			return onChunk(chunk)
+		case "message_stop":
+			return errStreamDone
+		case "error":
+			return &APIError{Provider: p.Name(), StatusCode: http.StatusOK, Message: event.Error.Message}
+		}
+		// content_block_stop、ping 无需转发
+		return nil
+	})
+}
+
+// anthropicFinishReason 转换结束原因，未知值原样返回
+func anthropicFinishReason(stopReason string) string {
+	if reason, ok := anthropicStopReasons[stopReason]; ok {
+		return reason
+	}
+	return stopReason
+}
+
+// MapError 解析 Messages API 的错误响应
+func (p *AnthropicProvider) MapError(resp *http.Response) error {
+	body, _ := io.ReadAll(resp.Body)
+	message := string(body)
+
+	var errorBody anthropicEvent
+	if err := json.Unmarshal(body, &errorBody); err == nil && errorBody.Error.Message != "" {
+		message = errorBody.Error.Message
+	}
+
+	return newAPIError(p.Name(), resp, message)
+}
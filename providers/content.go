@@ -0,0 +1,12 @@
+package providers
+
+import "strings"
+
+// parseDataURL 解析 data:<mime>;base64,<data> 形式的地址
+func parseDataURL(url string) (mediaType, data string, ok bool) {
+	meta, data, found := strings.Cut(strings.TrimPrefix(url, "data:"), ",")
+	if !strings.HasPrefix(url, "data:") || !found || !strings.HasSuffix(meta, ";base64") {
+		return "", "", false
+	}
+	return strings.TrimSuffix(meta, ";base64"), data, true
+}
@@ -0,0 +1,248 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/EthanGuo-coder/llm-backend-api/constant"
+	"github.com/EthanGuo-coder/llm-backend-api/models"
+)
+
+// replayed 回放一段录制的上游流后汇总的结果
+type replayed struct {
+	content      string
+	finishReason string
+	usage        *models.Usage
+	toolCalls    map[int]*ToolCallDelta // 按调用序号拼接后的工具调用
+	errors       []string
+	request      map[string]interface{} // 上游收到的请求体
+	header       http.Header
+}
+
+// replay 用 httptest 服务返回 testdata 中录制的流，经 BuildRequest 发起请求并用 DecodeStream 解码
+func replay(t *testing.T, newProvider func(baseURL string) Provider, fixture, contentType string) (*replayed, error) {
+	t.Helper()
+	recorded, err := os.ReadFile(filepath.Join("testdata", fixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := &replayed{toolCalls: make(map[int]*ToolCallDelta)}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		result.header = r.Header.Clone()
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &result.request)
+		w.Header().Set("Content-Type", contentType)
+		w.Write(recorded)
+	}))
+	defer server.Close()
+
+	provider := newProvider(server.URL)
+	req, err := provider.BuildRequest(context.Background(), &ChatRequest{
+		Model:    "test-model",
+		ApiKey:   "test-key",
+		Messages: []models.Message{{Role: "system", Content: "sys"}, {Role: "user", Content: "hi"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := provider.HTTPClient().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	err = provider.DecodeStream(resp.Body, func(chunk *StreamChunk) error {
+		if chunk.Error != "" {
+			result.errors = append(result.errors, chunk.Error)
+			return nil
+		}
+		result.content += chunk.Content
+		if chunk.FinishReason != "" {
+			result.finishReason = chunk.FinishReason
+		}
+		if chunk.Usage != nil {
+			result.usage = chunk.Usage
+		}
+		for _, delta := range chunk.ToolCalls {
+			call, ok := result.toolCalls[delta.Index]
+			if !ok {
+				call = &ToolCallDelta{Index: delta.Index}
+				result.toolCalls[delta.Index] = call
+			}
+			if delta.ID != "" {
+				call.ID = delta.ID
+			}
+			if delta.Name != "" {
+				call.Name = delta.Name
+			}
+			call.Arguments += delta.Arguments
+		}
+		return nil
+	})
+	return result, err
+}
+
+func TestAnthropicDecodeStream(t *testing.T) {
+	newProvider := func(baseURL string) Provider {
+		return NewAnthropicProvider(Options{Name: "anthropic", BaseURL: baseURL, Auth: constant.AuthXAPIKey})
+	}
+
+	t.Run("text and tool use", func(t *testing.T) {
+		got, err := replay(t, newProvider, "anthropic_stream.sse", "text/event-stream")
+		if err != nil {
+			t.Fatalf("DecodeStream() error = %v", err)
+		}
+		if got.content != "Let me check." {
+			t.Errorf("content = %q, want %q", got.content, "Let me check.")
+		}
+		if got.finishReason != "tool_calls" {
+			t.Errorf("finish reason = %q, want tool_calls", got.finishReason)
+		}
+		want := models.Usage{PromptTokens: 25, CompletionTokens: 40, TotalTokens: 65}
+		if got.usage == nil || *got.usage != want {
+			t.Errorf("usage = %+v, want %+v", got.usage, want)
+		}
+		call := got.toolCalls[1]
+		if len(got.toolCalls) != 1 || call == nil || call.ID != "toolu_01" || call.Name != "get_time" || call.Arguments != `{"timezone": "UTC"}` {
+			t.Errorf("tool calls = %+v", got.toolCalls)
+		}
+		if got.header.Get("x-api-key") != "test-key" || got.header.Get("anthropic-version") != constant.AnthropicVersion {
+			t.Errorf("headers = %v", got.header)
+		}
+		if got.request["system"] != "sys" || got.request["stream"] != true {
+			t.Errorf("request = %v", got.request)
+		}
+	})
+
+	t.Run("error event", func(t *testing.T) {
+		got, err := replay(t, newProvider, "anthropic_error.sse", "text/event-stream")
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.Message != "Overloaded" {
+			t.Fatalf("DecodeStream() error = %v, want APIError Overloaded", err)
+		}
+		if got.content != "Hel" {
+			t.Errorf("partial content = %q, want %q", got.content, "Hel")
+		}
+	})
+}
+
+func TestOpenAIDecodeStream(t *testing.T) {
+	newProvider := func(baseURL string) Provider {
+		return NewOpenAIProvider(Options{Name: "openai", BaseURL: baseURL, Auth: constant.AuthBearer})
+	}
+
+	got, err := replay(t, newProvider, "openai_stream.sse", "text/event-stream")
+	if err != nil {
+		t.Fatalf("DecodeStream() error = %v", err)
+	}
+	// [DONE] 之后的数据不再处理
+	if got.content != "Hello, world" {
+		t.Errorf("content = %q, want %q", got.content, "Hello, world")
+	}
+	if got.finishReason != "tool_calls" {
+		t.Errorf("finish reason = %q, want tool_calls", got.finishReason)
+	}
+	want := models.Usage{PromptTokens: 12, CompletionTokens: 9, TotalTokens: 21}
+	if got.usage == nil || *got.usage != want {
+		t.Errorf("usage = %+v, want %+v", got.usage, want)
+	}
+	call := got.toolCalls[0]
+	if len(got.toolCalls) != 1 || call == nil || call.ID != "call_1" || call.Name != "get_time" || call.Arguments != `{"timezone": "UTC"}` {
+		t.Errorf("tool calls = %+v", got.toolCalls)
+	}
+	// 无法解析的单条数据作为错误增量上报，不中断整个流
+	if len(got.errors) != 1 {
+		t.Errorf("errors = %v, want one unmarshal error", got.errors)
+	}
+	if got.header.Get("Authorization") != "Bearer test-key" {
+		t.Errorf("Authorization = %q", got.header.Get("Authorization"))
+	}
+	options, _ := got.request["stream_options"].(map[string]interface{})
+	if options["include_usage"] != true {
+		t.Errorf("stream_options = %v, want include_usage", got.request["stream_options"])
+	}
+}
+
+func TestOllamaDecodeStream(t *testing.T) {
+	newProvider := func(baseURL string) Provider {
+		return NewOllamaProvider(Options{Name: "ollama", BaseURL: baseURL + "/"})
+	}
+
+	t.Run("content and tool calls", func(t *testing.T) {
+		got, err := replay(t, newProvider, "ollama_stream.ndjson", "application/x-ndjson")
+		if err != nil {
+			t.Fatalf("DecodeStream() error = %v", err)
+		}
+		// done 之后的行不再处理
+		if got.content != "Hi there" {
+			t.Errorf("content = %q, want %q", got.content, "Hi there")
+		}
+		if got.finishReason != "stop" {
+			t.Errorf("finish reason = %q, want stop", got.finishReason)
+		}
+		want := models.Usage{PromptTokens: 30, CompletionTokens: 7, TotalTokens: 37}
+		if got.usage == nil || *got.usage != want {
+			t.Errorf("usage = %+v, want %+v", got.usage, want)
+		}
+		if len(got.toolCalls) != 2 || got.toolCalls[0].Name != "get_time" || got.toolCalls[0].Arguments != `{"timezone":"UTC"}` ||
+			got.toolCalls[1].Name != "search" || got.toolCalls[1].Arguments != `{"q":"go"}` {
+			t.Errorf("tool calls = %+v", got.toolCalls)
+		}
+		if len(got.errors) != 1 {
+			t.Errorf("errors = %v, want one unmarshal error", got.errors)
+		}
+		if got.header.Get("Authorization") != "" {
+			t.Errorf("Authorization = %q, want none without auth", got.header.Get("Authorization"))
+		}
+	})
+
+	t.Run("error line", func(t *testing.T) {
+		got, err := replay(t, newProvider, "ollama_error.ndjson", "application/x-ndjson")
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.Message != "model crashed" {
+			t.Fatalf("DecodeStream() error = %v, want APIError model crashed", err)
+		}
+		if got.content != "x" {
+			t.Errorf("partial content = %q, want %q", got.content, "x")
+		}
+	})
+}
+
+func TestReadSSE(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream string
+		want   []string // event|data
+	}{
+		{"lf frames", "event: a\ndata: 1\n\ndata: 2\n\n", []string{"a|1", "|2"}},
+		{"crlf frames", "data: 1\r\n\r\ndata: 2\r\n\r\n", []string{"|1", "|2"}},
+		{"multi-line data", "data: a\ndata: b\n\n", []string{"|a\nb"}},
+		{"comments and empty frames", ": ping\n\n\n\ndata: x\n\n", []string{"|x"}},
+		{"last frame without blank line", "data: 1\n\ndata: 2", []string{"|1", "|2"}},
+		{"no space after colon", "data:1\n\n", []string{"|1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			err := readSSE(strings.NewReader(tt.stream), func(event string, data []byte) error {
+				got = append(got, event+"|"+string(data))
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("readSSE() error = %v", err)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("events = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
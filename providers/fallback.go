@@ -0,0 +1,35 @@
+package providers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/EthanGuo-coder/llm-backend-api/config"
+)
+
+// fallbacks 模型（小写）到降级模型列表的映射
+var fallbacks map[string][]string
+
+// initializeFallbacks 加载并校验降级链，链中每个模型都必须能匹配到服务商
+func initializeFallbacks() error {
+	built := make(map[string][]string, len(config.AppConfig.Fallbacks))
+	for _, cfg := range config.AppConfig.Fallbacks {
+		if cfg.Model == "" {
+			return fmt.Errorf("fallbacks: model is required")
+		}
+		for _, model := range cfg.Chain {
+			if _, err := Resolve(model); err != nil {
+				return fmt.Errorf("fallbacks for %q: %w", cfg.Model, err)
+			}
+		}
+		built[strings.ToLower(cfg.Model)] = cfg.Chain
+	}
+
+	fallbacks = built
+	return nil
+}
+
+// Chain 返回依次尝试的模型列表：模型本身在前，其后为配置的降级模型
+func Chain(model string) []string {
+	return append([]string{model}, fallbacks[strings.ToLower(model)]...)
+}
@@ -0,0 +1,27 @@
+package providers
+
+import "github.com/EthanGuo-coder/llm-backend-api/models"
+
+// GLMProvider 智谱 GLM 服务商，接口与 OpenAI Chat Completions 协议兼容
+type GLMProvider struct {
+	OpenAIProvider
+}
+
+// NewGLMProvider 创建 GLM 服务商。GLM 不支持 stream_options，最后一个增量中总是带有 token 用量
+func NewGLMProvider(opts Options) *GLMProvider {
+	return &GLMProvider{OpenAIProvider{base: newBase(opts)}}
+}
+
+// ValidateParams GLM 的 temperature 范围为 [0, 1]，仅支持一个停止词，且不支持 seed 与 penalty
+func (p *GLMProvider) ValidateParams(params *models.GenerationParams) error {
+	if params == nil {
+		return nil
+	}
+	return firstError(
+		checkRange("temperature", params.Temperature, 0, 1),
+		checkRange("top_p", params.TopP, 0, 1),
+		checkMaxTokens(params),
+		checkStop(params, 1),
+		checkUnsupported(p.Name(), params),
+	)
+}
@@ -0,0 +1,243 @@
+package providers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/EthanGuo-coder/llm-backend-api/models"
+)
+
+// OllamaProvider 本地 Ollama 服务商，流式响应为换行分隔的 JSON（NDJSON）
+type OllamaProvider struct {
+	base
+}
+
+// NewOllamaProvider 创建 Ollama 服务商，BaseURL 为服务根地址，如 http://localhost:11434
+func NewOllamaProvider(opts Options) *OllamaProvider {
+	opts.BaseURL = strings.TrimRight(opts.BaseURL, "/")
+	return &OllamaProvider{newBase(opts)}
+}
+
+// ollamaMessage Ollama 协议中的单条消息，工具参数为 JSON 对象
+type ollamaMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	ToolCalls []ollamaToolCall `json:"tool_calls,omitempty"`
+	ToolName  string           `json:"tool_name,omitempty"`
+	Images    []string         `json:"images,omitempty"` // base64 编码的图片，不带 data URL 前缀
+}
+
+// ollamaToolCall Ollama 的工具调用，不携带调用 ID
+type ollamaToolCall struct {
+	Function struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	} `json:"function"`
+}
+
+// ollamaChunk Ollama /api/chat 的单行流式数据
+type ollamaChunk struct {
+	Message struct {
+		Content   string           `json:"content"`
+		ToolCalls []ollamaToolCall `json:"tool_calls"`
+	} `json:"message"`
+	Done            bool   `json:"done"`
+	DoneReason      string `json:"done_reason"`
+	PromptEvalCount int    `json:"prompt_eval_count"`
+	EvalCount       int    `json:"eval_count"`
+	Error           string `json:"error"`
+}
+
+// ValidateParams 校验生成参数，Ollama 支持全部参数
+func (p *OllamaProvider) ValidateParams(params *models.GenerationParams) error {
+	if params == nil {
+		return nil
+	}
+	return firstError(
+		checkRange("temperature", params.Temperature, 0, 2),
+		checkRange("top_p", params.TopP, 0, 1),
+		checkMaxTokens(params),
+	)
+}
+
+// upstreamModel 去掉路由命中的模型名前缀，如 ollama/llama3 -> llama3；经正则命中的模型名原样发送
+func (p *OllamaProvider) upstreamModel(model string) string {
+	if prefix, ok := matchPrefix(p.opts.ModelPrefixes, model); ok {
+		return model[len(prefix):]
+	}
+	return model
+}
+
+// BuildRequest 构造 /api/chat 流式请求
+func (p *OllamaProvider) BuildRequest(ctx context.Context, req *ChatRequest) (*http.Request, error) {
+	requestBody := map[string]interface{}{
+		"model":    p.upstreamModel(req.Model),
+		"messages": toOllamaMessages(req.Messages),
+		"stream":   true,
+	}
+	if len(req.Tools) > 0 {
+		requestBody["tools"] = toOpenAITools(req.Tools)
+	}
+	if options := ollamaOptions(req.Params); len(options) > 0 {
+		requestBody["options"] = options
+	}
+	requestData, err := json.Marshal(requestBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request body: %w", err)
+	}
+
+	apiReq, err := http.NewRequestWithContext(ctx, "POST", p.opts.BaseURL+"/api/chat", bytes.NewBuffer(requestData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	// 本地运行无需鉴权，未填写密钥时不会携带鉴权头
+	p.setHeaders(apiReq, req.ApiKey)
+	return apiReq, nil
+}
+
+// toOllamaMessages 转换对话历史，工具参数由 JSON 字符串还原为对象
+func toOllamaMessages(history []models.Message) []ollamaMessage {
+	messages := make([]ollamaMessage, 0, len(history))
+	for _, message := range history {
+		converted := ollamaMessage{Role: message.Role, Content: message.Content, ToolName: message.Name}
+		if len(message.Parts) > 0 {
+			converted.Content, converted.Images = toOllamaContent(message.Parts)
+		}
+		for _, call := range message.ToolCalls {
+			var toolCall ollamaToolCall
+			toolCall.Function.Name = call.Name
+			toolCall.Function.Arguments = json.RawMessage(call.Arguments)
+			if !json.Valid(toolCall.Function.Arguments) {
+				toolCall.Function.Arguments = json.RawMessage("{}")
+			}
+			converted.ToolCalls = append(converted.ToolCalls, toolCall)
+		}
+		messages = append(messages, converted)
+	}
+	return messages
+}
+
+// toOllamaContent 拆分多模态片段：文本拼接为 content，内联图片放入 images。
+// Ollama 只接受 base64 图片，远程图片与文件以文本占位
+func toOllamaContent(parts []models.ContentPart) (string, []string) {
+	var texts, images []string
+	for _, part := range parts {
+		switch {
+		case part.Type == models.PartText:
+			texts = append(texts, part.Text)
+		case part.Type == models.PartImageURL && part.ImageURL != nil && part.ImageURL.URL != "":
+			if _, data, ok := parseDataURL(part.ImageURL.URL); ok {
+				images = append(images, data)
+			} else {
+				texts = append(texts, fmt.Sprintf("[image: %s]", part.ImageURL.URL))
+			}
+		case part.Type == models.PartFile && part.File != nil:
+			texts = append(texts, fmt.Sprintf("[file: %s]", part.File.Filename))
+		}
+	}
+	return strings.Join(texts, "\n"), images
+}
+
+// ollamaOptions 将生成参数转换为 Ollama 的 options，max_tokens 对应 num_predict
+func ollamaOptions(params *models.GenerationParams) map[string]interface{} {
+	options := map[string]interface{}{}
+	if params == nil {
+		return options
+	}
+	if params.Temperature != nil {
+		options["temperature"] = *params.Temperature
+	}
+	if params.TopP != nil {
+		options["top_p"] = *params.TopP
+	}
+	if params.MaxTokens != nil {
+		options["num_predict"] = *params.MaxTokens
+	}
+	if len(params.Stop) > 0 {
+		options["stop"] = params.Stop
+	}
+	if params.Seed != nil {
+		options["seed"] = *params.Seed
+	}
+	if params.PresencePenalty != nil {
+		options["presence_penalty"] = *params.PresencePenalty
+	}
+	if params.FrequencyPenalty != nil {
+		options["frequency_penalty"] = *params.FrequencyPenalty
+	}
+	return options
+}
+
+// DecodeStream 逐行解码 NDJSON 流
+func (p *OllamaProvider) DecodeStream(body io.Reader, onChunk func(chunk *StreamChunk) error) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	// Ollama 一次性给出完整的工具调用，按出现顺序编号
+	var toolCalls int
+
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var chunk ollamaChunk
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			if err := onChunk(&StreamChunk{Error: fmt.Sprintf("Failed to unmarshal NDJSON data: %v", err)}); err != nil {
+				return err
+			}
+			continue
+		}
+		if chunk.Error != "" {
+			return &APIError{Provider: p.Name(), StatusCode: http.StatusOK, Message: chunk.Error}
+		}
+
+		streamChunk := &StreamChunk{Content: chunk.Message.Content}
+		for _, call := range chunk.Message.ToolCalls {
+			streamChunk.ToolCalls = append(streamChunk.ToolCalls, ToolCallDelta{
+				Index:     toolCalls,
+				Name:      call.Function.Name,
+				Arguments: string(call.Function.Arguments),
+			})
+			toolCalls++
+		}
+		if chunk.Done {
+			streamChunk.FinishReason = chunk.DoneReason
+			streamChunk.Usage = &models.Usage{
+				PromptTokens:     chunk.PromptEvalCount,
+				CompletionTokens: chunk.EvalCount,
+				TotalTokens:      chunk.PromptEvalCount + chunk.EvalCount,
+			}
+		}
+		if err := onChunk(streamChunk); err != nil {
+			return err
+		}
+		if chunk.Done {
+			return nil
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading stream: %w", err)
+	}
+	return nil
+}
+
+// MapError 解析 Ollama 的错误响应
+func (p *OllamaProvider) MapError(resp *http.Response) error {
+	body, _ := io.ReadAll(resp.Body)
+	message := string(body)
+
+	var errorBody ollamaChunk
+	if err := json.Unmarshal(body, &errorBody); err == nil && errorBody.Error != "" {
+		message = errorBody.Error
+	}
+
+	return newAPIError(p.Name(), resp, message)
+}
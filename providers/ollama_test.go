@@ -0,0 +1,45 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/EthanGuo-coder/llm-backend-api/models"
+)
+
+func TestOllamaUpstreamModel(t *testing.T) {
+	tests := []struct {
+		name     string
+		prefixes []string
+		model    string
+		want     string
+	}{
+		{"default prefix", []string{"ollama/"}, "ollama/llama3", "llama3"},
+		{"custom prefix", []string{"local/"}, "local/qwen2:7b", "qwen2:7b"},
+		{"second prefix", []string{"ollama/", "local/"}, "local/llama3", "llama3"},
+		{"case-insensitive", []string{"local/"}, "LOCAL/llama3", "llama3"},
+		{"pattern match", []string{"ollama/"}, "llama3", "llama3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := NewOllamaProvider(Options{Name: "ollama", BaseURL: "http://localhost:11434", ModelPrefixes: tt.prefixes})
+			req, err := provider.BuildRequest(context.Background(), &ChatRequest{
+				Model:    tt.model,
+				Messages: []models.Message{{Role: "user", Content: "hi"}},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := io.ReadAll(req.Body)
+			var sent struct {
+				Model string `json:"model"`
+			}
+			json.Unmarshal(body, &sent)
+			if sent.Model != tt.want {
+				t.Errorf("model = %q, want %q", sent.Model, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,240 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/EthanGuo-coder/llm-backend-api/models"
+)
+
+// OpenAIProvider OpenAI Chat Completions 协议的服务商
+type OpenAIProvider struct {
+	base
+	streamUsage bool // 请求 stream_options.include_usage，流末尾才会返回 token 用量；非流式接口返回的 usage 同样依赖它
+}
+
+// NewOpenAIProvider 创建 OpenAI 服务商，也适用于 Azure OpenAI、vLLM 等兼容服务
+func NewOpenAIProvider(opts Options) *OpenAIProvider {
+	return &OpenAIProvider{base: newBase(opts), streamUsage: true}
+}
+
+// openAIMessage OpenAI 协议中的单条消息，Content 为字符串或 content parts
+type openAIMessage struct {
+	Role       string           `json:"role"`
+	Content    interface{}      `json:"content"`
+	ToolCalls  []openAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+// openAIContentPart 多模态消息片段
+type openAIContentPart struct {
+	Type     string          `json:"type"`
+	Text     string          `json:"text,omitempty"`
+	ImageURL *openAIImageURL `json:"image_url,omitempty"`
+	File     *openAIFile     `json:"file,omitempty"`
+}
+
+// openAIImageURL 图片地址，可为 http(s) 地址或 data URL
+type openAIImageURL struct {
+	URL    string `json:"url"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// openAIFile 内联文件，FileData 为 data URL
+type openAIFile struct {
+	Filename string `json:"filename,omitempty"`
+	FileData string `json:"file_data"`
+}
+
+// openAIToolCall assistant 消息中的工具调用
+type openAIToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// openAITool 请求中声明的函数工具
+type openAITool struct {
+	Type     string                `json:"type"`
+	Function models.ToolDefinition `json:"function"`
+}
+
+// openAIErrorBody OpenAI 协议的错误响应体
+type openAIErrorBody struct {
+	Error struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// ValidateParams 按 Chat Completions 的取值范围校验生成参数
+func (p *OpenAIProvider) ValidateParams(params *models.GenerationParams) error {
+	if params == nil {
+		return nil
+	}
+	return firstError(
+		checkRange("temperature", params.Temperature, 0, 2),
+		checkRange("top_p", params.TopP, 0, 1),
+		checkMaxTokens(params),
+		checkStop(params, 4),
+		checkRange("presence_penalty", params.PresencePenalty, -2, 2),
+		checkRange("frequency_penalty", params.FrequencyPenalty, -2, 2),
+	)
+}
+
+// BuildRequest 构造 Chat Completions 流式请求
+func (p *OpenAIProvider) BuildRequest(ctx context.Context, req *ChatRequest) (*http.Request, error) {
+	requestBody := map[string]interface{}{
+		"model":    req.Model,
+		"messages": toOpenAIMessages(req.Messages),
+		"stream":   true,
+	}
+	if p.streamUsage {
+		requestBody["stream_options"] = map[string]interface{}{"include_usage": true}
+	}
+	if len(req.Tools) > 0 {
+		requestBody["tools"] = toOpenAITools(req.Tools)
+	}
+	applyOpenAIParams(requestBody, req.Params)
+	requestData, err := json.Marshal(requestBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request body: %w", err)
+	}
+
+	apiReq, err := http.NewRequestWithContext(ctx, "POST", p.opts.BaseURL, bytes.NewBuffer(requestData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	p.setHeaders(apiReq, req.ApiKey)
+	return apiReq, nil
+}
+
+// toOpenAIMessages 转换对话历史，保留工具调用与工具结果
+func toOpenAIMessages(history []models.Message) []openAIMessage {
+	messages := make([]openAIMessage, 0, len(history))
+	for _, message := range history {
+		converted := openAIMessage{Role: message.Role, Content: message.Content, ToolCallID: message.ToolCallID}
+		if len(message.Parts) > 0 {
+			converted.Content = toOpenAIParts(message.Parts)
+		}
+		for _, call := range message.ToolCalls {
+			toolCall := openAIToolCall{ID: call.ID, Type: "function"}
+			toolCall.Function.Name = call.Name
+			toolCall.Function.Arguments = call.Arguments
+			converted.ToolCalls = append(converted.ToolCalls, toolCall)
+		}
+		messages = append(messages, converted)
+	}
+	return messages
+}
+
+// toOpenAIParts 转换多模态片段，图片与文件需已填充为 URL 或 data URL
+func toOpenAIParts(parts []models.ContentPart) []openAIContentPart {
+	converted := make([]openAIContentPart, 0, len(parts))
+	for _, part := range parts {
+		switch {
+		case part.Type == models.PartText:
+			converted = append(converted, openAIContentPart{Type: "text", Text: part.Text})
+		case part.Type == models.PartImageURL && part.ImageURL != nil && part.ImageURL.URL != "":
+			converted = append(converted, openAIContentPart{
+				Type:     "image_url",
+				ImageURL: &openAIImageURL{URL: part.ImageURL.URL, Detail: part.ImageURL.Detail},
+			})
+		case part.Type == models.PartFile && part.File != nil && part.File.Data != "":
+			converted = append(converted, openAIContentPart{
+				Type: "file",
+				File: &openAIFile{Filename: part.File.Filename, FileData: part.File.Data},
+			})
+		}
+	}
+	return converted
+}
+
+// toOpenAITools 转换工具声明
+func toOpenAITools(definitions []models.ToolDefinition) []openAITool {
+	tools := make([]openAITool, 0, len(definitions))
+	for _, definition := range definitions {
+		tools = append(tools, openAITool{Type: "function", Function: definition})
+	}
+	return tools
+}
+
+// applyOpenAIParams 将已设置的生成参数写入请求体
+func applyOpenAIParams(requestBody map[string]interface{}, params *models.GenerationParams) {
+	if params == nil {
+		return
+	}
+	if params.Temperature != nil {
+		requestBody["temperature"] = *params.Temperature
+	}
+	if params.TopP != nil {
+		requestBody["top_p"] = *params.TopP
+	}
+	if params.MaxTokens != nil {
+		requestBody["max_tokens"] = *params.MaxTokens
+	}
+	if len(params.Stop) > 0 {
+		requestBody["stop"] = params.Stop
+	}
+	if params.Seed != nil {
+		requestBody["seed"] = *params.Seed
+	}
+	if params.PresencePenalty != nil {
+		requestBody["presence_penalty"] = *params.PresencePenalty
+	}
+	if params.FrequencyPenalty != nil {
+		requestBody["frequency_penalty"] = *params.FrequencyPenalty
+	}
+}
+
+// DecodeStream 解码 `data: ` 形式的 SSE 流
+func (p *OpenAIProvider) DecodeStream(body io.Reader, onChunk func(chunk *StreamChunk) error) error {
+	return readSSE(body, func(_ string, data []byte) error {
+		if string(data) == "[DONE]" {
+			return errStreamDone
+		}
+
+		var sseResponse models.SSEResponse
+		if err := json.Unmarshal(data, &sseResponse); err != nil {
+			return onChunk(&StreamChunk{Error: fmt.Sprintf("Failed to unmarshal SSE data: %v", err)})
+		}
+
+		for _, choice := range sseResponse.Choices {
+			chunk := &StreamChunk{Content: choice.Delta.Content, FinishReason: choice.FinishReason}
+			for _, call := range choice.Delta.ToolCalls {
+				chunk.ToolCalls = append(chunk.ToolCalls, ToolCallDelta{
+					Index:     call.Index,
+					ID:        call.ID,
+					Name:      call.Function.Name,
+					Arguments: call.Function.Arguments,
+				})
+			}
+			if err := onChunk(chunk); err != nil {
+				return err
+			}
+		}
+		if sseResponse.Usage != nil {
+			return onChunk(&StreamChunk{Usage: sseResponse.Usage})
+		}
+		return nil
+	})
+}
+
+// MapError 解析 OpenAI 风格的错误响应
+func (p *OpenAIProvider) MapError(resp *http.Response) error {
+	body, _ := io.ReadAll(resp.Body)
+	message := string(body)
+
+	var errorBody openAIErrorBody
+	if err := json.Unmarshal(body, &errorBody); err == nil && errorBody.Error.Message != "" {
+		message = errorBody.Error.Message
+	}
+
+	return newAPIError(p.Name(), resp, message)
+}
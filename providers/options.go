@@ -0,0 +1,64 @@
+package providers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/EthanGuo-coder/llm-backend-api/constant"
+)
+
+// Options 服务商通用配置
+type Options struct {
+	Name    string
+	BaseURL string
+	Headers map[string]string // 额外请求头
+	Auth    string            // 鉴权方式，见 constant.Auth*
+	APIKey  string            // 服务端密钥，请求未携带密钥时使用
+	Timeout time.Duration     // 等待上游响应头的超时，0 表示不限
+	// ModelPrefixes 路由匹配用的模型名前缀（小写），需要去掉前缀再发往上游的服务商（如 Ollama）使用
+	ModelPrefixes []string
+}
+
+// base 各服务商共用的配置与 HTTP 客户端
+type base struct {
+	opts   Options
+	client *http.Client
+}
+
+func newBase(opts Options) base {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// 只限制等待响应头的时间，不限制流式响应本身的持续时间
+	transport.ResponseHeaderTimeout = opts.Timeout
+	return base{opts: opts, client: &http.Client{Transport: transport}}
+}
+
+// Name 返回服务商名称
+func (b *base) Name() string {
+	return b.opts.Name
+}
+
+// HTTPClient 返回该服务商专用的 HTTP 客户端
+func (b *base) HTTPClient() *http.Client {
+	return b.client
+}
+
+// setHeaders 设置鉴权头与自定义请求头
+func (b *base) setHeaders(req *http.Request, apiKey string) {
+	req.Header.Set("Content-Type", "application/json")
+	if apiKey == "" {
+		apiKey = b.opts.APIKey
+	}
+	if apiKey != "" {
+		switch b.opts.Auth {
+		case constant.AuthBearer:
+			req.Header.Set("Authorization", "Bearer "+apiKey)
+		case constant.AuthAPIKey:
+			req.Header.Set("api-key", apiKey)
+		case constant.AuthXAPIKey:
+			req.Header.Set("x-api-key", apiKey)
+		}
+	}
+	for key, value := range b.opts.Headers {
+		req.Header.Set(key, value)
+	}
+}
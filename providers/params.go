@@ -0,0 +1,63 @@
+package providers
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/EthanGuo-coder/llm-backend-api/models"
+)
+
+// ErrInvalidParams 生成参数超出取值范围或不被服务商支持
+var ErrInvalidParams = errors.New("invalid generation params")
+
+// invalidParams 构造参数校验错误
+func invalidParams(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrInvalidParams, fmt.Sprintf(format, args...))
+}
+
+// checkRange 校验可选浮点参数的取值范围
+func checkRange(name string, value *float64, min, max float64) error {
+	if value != nil && (*value < min || *value > max) {
+		return invalidParams("%s must be between %g and %g", name, min, max)
+	}
+	return nil
+}
+
+// checkMaxTokens 校验 max_tokens 为正数
+func checkMaxTokens(params *models.GenerationParams) error {
+	if params.MaxTokens != nil && *params.MaxTokens <= 0 {
+		return invalidParams("max_tokens must be positive")
+	}
+	return nil
+}
+
+// checkStop 校验停止序列数量
+func checkStop(params *models.GenerationParams, max int) error {
+	if len(params.Stop) > max {
+		return invalidParams("at most %d stop sequences are supported", max)
+	}
+	return nil
+}
+
+// checkUnsupported 服务商不支持 seed 与 presence/frequency penalty 时拒绝这些参数
+func checkUnsupported(provider string, params *models.GenerationParams) error {
+	switch {
+	case params.Seed != nil:
+		return invalidParams("seed is not supported by %s", provider)
+	case params.PresencePenalty != nil:
+		return invalidParams("presence_penalty is not supported by %s", provider)
+	case params.FrequencyPenalty != nil:
+		return invalidParams("frequency_penalty is not supported by %s", provider)
+	}
+	return nil
+}
+
+// firstError 返回第一个非空错误
+func firstError(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/EthanGuo-coder/llm-backend-api/models"
+)
+
+// Provider 大模型服务商适配接口，负责请求构造、鉴权、流式解码与错误映射
+type Provider interface {
+	// Name 返回服务商名称
+	Name() string
+	// HTTPClient 返回该服务商专用的 HTTP 客户端
+	HTTPClient() *http.Client
+	// ValidateParams 校验生成参数是否被该服务商支持，失败时返回 ErrInvalidParams
+	ValidateParams(params *models.GenerationParams) error
+	// BuildRequest 根据对话请求构造上游 HTTP 请求（包含鉴权头），参数需已通过 ValidateParams 校验
+	BuildRequest(ctx context.Context, req *ChatRequest) (*http.Request, error)
+	// DecodeStream 解码上游流式响应，每解析出一个增量调用一次 onChunk
+	DecodeStream(body io.Reader, onChunk func(chunk *StreamChunk) error) error
+	// MapError 将非 200 的上游响应映射为统一的错误
+	MapError(resp *http.Response) error
+}
+
+// ChatRequest 与服务商无关的对话请求
+type ChatRequest struct {
+	Model    string
+	ApiKey   string
+	Messages []models.Message
+	Params   *models.GenerationParams // 可为空
+	Tools    []models.ToolDefinition  // 向模型声明的工具，可为空
+}
+
+// StreamChunk 与服务商无关的流式增量
+type StreamChunk struct {
+	Content      string
+	FinishReason string
+	Usage        *models.Usage // 上游报告的 token 用量，通常只出现在最后一个增量中
+	ToolCalls    []ToolCallDelta
+	// Error 单条数据解析失败时的错误信息，不中断整个流
+	Error string
+}
+
+// ToolCallDelta 流式工具调用片段。Index 标识所属调用，ID 与 Name 只在首个片段中出现，
+// Arguments 需按顺序拼接后才是完整的 JSON
+type ToolCallDelta struct {
+	Index     int
+	ID        string
+	Name      string
+	Arguments string
+}
+
+// APIError 上游服务商返回的错误
+type APIError struct {
+	Provider   string
+	StatusCode int
+	Message    string
+	RetryAfter time.Duration // 上游通过 Retry-After 头建议的等待时间
+}
+
+// newAPIError 根据非 200 响应构造错误，并解析 Retry-After 头
+func newAPIError(provider string, resp *http.Response, message string) *APIError {
+	return &APIError{
+		Provider:   provider,
+		StatusCode: resp.StatusCode,
+		Message:    message,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+}
+
+// parseRetryAfter 解析秒数或 HTTP 日期形式的 Retry-After
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if delay := time.Until(date); delay > 0 {
+			return delay
+		}
+	}
+	return 0
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("unexpected response status from %s (%d): %s", e.Provider, e.StatusCode, e.Message)
+}
+
+// Temporary 限流与服务端错误属于临时错误，可重试或降级
+func (e *APIError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= http.StatusInternalServerError
+}
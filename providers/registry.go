@@ -0,0 +1,125 @@
+package providers
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/EthanGuo-coder/llm-backend-api/config"
+	"github.com/EthanGuo-coder/llm-backend-api/constant"
+	"github.com/EthanGuo-coder/llm-backend-api/models"
+)
+
+// ErrUnsupportedModel 模型名没有匹配到任何服务商
+var ErrUnsupportedModel = errors.New("unsupported model")
+
+// route 模型匹配规则与服务商的对应关系
+type route struct {
+	provider Provider
+	prefixes []string
+	patterns []*regexp.Regexp
+}
+
+// routes 按配置顺序匹配的路由表
+var routes []route
+
+// defaultAuth 各协议未指定鉴权方式时的默认值
+var defaultAuth = map[string]string{
+	constant.ProviderTypeOpenAI:    constant.AuthBearer,
+	constant.ProviderTypeGLM:       constant.AuthBearer,
+	constant.ProviderTypeAnthropic: constant.AuthXAPIKey,
+	constant.ProviderTypeOllama:    constant.AuthBearer,
+}
+
+// InitializeProviders 根据配置构建服务商注册表
+func InitializeProviders() error {
+	built := make([]route, 0, len(config.AppConfig.Providers))
+	for _, cfg := range config.AppConfig.Providers {
+		r, err := newRoute(cfg)
+		if err != nil {
+			return err
+		}
+		built = append(built, r)
+	}
+
+	routes = built
+	return initializeFallbacks()
+}
+
+// newRoute 根据单个服务商配置创建路由
+func newRoute(cfg models.ProviderConfig) (route, error) {
+	opts := Options{
+		Name:    cfg.Name,
+		BaseURL: cfg.BaseURL,
+		Headers: cfg.Headers,
+		Auth:    cfg.Auth,
+		APIKey:  cfg.APIKey,
+		Timeout: time.Duration(cfg.Timeout) * time.Second,
+	}
+	if opts.Auth == "" {
+		opts.Auth = defaultAuth[cfg.Type]
+	}
+
+	for _, prefix := range cfg.ModelPrefixes {
+		opts.ModelPrefixes = append(opts.ModelPrefixes, strings.ToLower(prefix))
+	}
+
+	r := route{prefixes: opts.ModelPrefixes}
+	switch cfg.Type {
+	case constant.ProviderTypeOpenAI:
+		r.provider = NewOpenAIProvider(opts)
+	case constant.ProviderTypeGLM:
+		r.provider = NewGLMProvider(opts)
+	case constant.ProviderTypeAnthropic:
+		r.provider = NewAnthropicProvider(opts)
+	case constant.ProviderTypeOllama:
+		r.provider = NewOllamaProvider(opts)
+	default:
+		return r, fmt.Errorf("provider %q: unsupported type %q", cfg.Name, cfg.Type)
+	}
+
+	for _, pattern := range cfg.ModelPatterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return r, fmt.Errorf("provider %q: invalid model pattern %q: %w", cfg.Name, pattern, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+// match 判断模型名是否命中该路由
+func (r *route) match(model string) bool {
+	if _, ok := matchPrefix(r.prefixes, model); ok {
+		return true
+	}
+	for _, re := range r.patterns {
+		if re.MatchString(model) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPrefix 返回模型名命中的第一个前缀，前缀为小写，匹配不区分大小写
+func matchPrefix(prefixes []string, model string) (string, bool) {
+	keyword := strings.ToLower(model)
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(keyword, prefix) {
+			return prefix, true
+		}
+	}
+	return "", false
+}
+
+// Resolve 根据模型名匹配服务商，按配置顺序取第一个命中的
+func Resolve(model string) (Provider, error) {
+	for i := range routes {
+		if routes[i].match(model) {
+			return routes[i].provider, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedModel, model)
+}
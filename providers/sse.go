@@ -0,0 +1,67 @@
+package providers
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// errStreamDone 用于在收到结束标志时提前停止读取
+var errStreamDone = errors.New("stream done")
+
+// readSSE 逐帧读取 SSE 流，每遇到空行分发一次 event/data
+func readSSE(body io.Reader, onEvent func(event string, data []byte) error) error {
+	reader := bufio.NewReader(body)
+	var event string
+	var data bytes.Buffer
+
+	dispatch := func() error {
+		defer func() {
+			event = ""
+			data.Reset()
+		}()
+		if data.Len() == 0 {
+			return nil
+		}
+		return onEvent(event, bytes.TrimSuffix(data.Bytes(), []byte("\n")))
+	}
+
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return fmt.Errorf("error reading stream: %w", readErr)
+		}
+
+		line = bytes.TrimRight(line, "\r\n")
+		switch {
+		case len(line) == 0:
+			if readErr == nil {
+				if err := dispatch(); err != nil {
+					return stopOnDone(err)
+				}
+			}
+		case line[0] == ':':
+			// 注释行
+		case bytes.HasPrefix(line, []byte("event:")):
+			event = string(bytes.TrimSpace(line[len("event:"):]))
+		case bytes.HasPrefix(line, []byte("data:")):
+			data.Write(bytes.TrimPrefix(line[len("data:"):], []byte(" ")))
+			data.WriteByte('\n')
+		}
+
+		if readErr == io.EOF {
+			// 流结束时分发尚未以空行结尾的最后一帧
+			return stopOnDone(dispatch())
+		}
+	}
+}
+
+// stopOnDone 将结束标志转换为正常返回
+func stopOnDone(err error) error {
+	if errors.Is(err, errStreamDone) {
+		return nil
+	}
+	return err
+}
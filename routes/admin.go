@@ -0,0 +1,74 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/EthanGuo-coder/llm-backend-api/middleware"
+	"github.com/EthanGuo-coder/llm-backend-api/models"
+	"github.com/EthanGuo-coder/llm-backend-api/services"
+)
+
+// RegisterAdminRoutes 注册管理员路由
+func RegisterAdminRoutes(r *gin.Engine) {
+	group := r.Group("/api/admin")
+	group.Use(middleware.AuthMiddleware(), middleware.RateLimitMiddleware("admin"), middleware.AdminMiddleware())
+	{
+		group.GET("/quotas/:user_id", getUserQuota)         // 查看用户配额与已用额度
+		group.POST("/quotas/:user_id", setUserQuota)        // 为用户单独设置配额
+		group.POST("/quotas/del/:user_id", deleteUserQuota) // 删除用户单独设置的配额，恢复默认
+	}
+}
+
+func getUserQuota(c *gin.Context) {
+	userID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	status, err := services.GetQuotaStatus(userID)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+func setUserQuota(c *gin.Context) {
+	userID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var quota models.UserQuota
+	if err := c.ShouldBindJSON(&quota); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	status, err := services.SetUserQuota(userID, &quota)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+func deleteUserQuota(c *gin.Context) {
+	userID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	status, err := services.DeleteUserQuota(userID)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
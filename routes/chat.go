@@ -1,6 +1,7 @@
 package routes
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -8,13 +9,24 @@ import (
 
 	"github.com/EthanGuo-coder/llm-backend-api/middleware"
 	"github.com/EthanGuo-coder/llm-backend-api/models"
+	"github.com/EthanGuo-coder/llm-backend-api/providers"
 	"github.com/EthanGuo-coder/llm-backend-api/services"
+	"github.com/EthanGuo-coder/llm-backend-api/utils"
 )
 
 func RegisterChatRoutes(r *gin.Engine) {
 	group := r.Group("/api/chat/:conversation_id")
+	group.Use(middleware.AuthMiddleware(), middleware.RateLimitMiddleware("chat"))
 	{
-		group.POST("/", middleware.AuthMiddleware(), streamSendMessage) // 流式返回消息
+		group.POST("/", middleware.QuotaMiddleware(), streamSendMessage)   // 流式返回消息
+		group.POST("/complete", middleware.QuotaMiddleware(), sendMessage) // 非流式返回完整消息
+		group.GET("/resume", resumeStream)                                 // 断线续传
+		group.POST("/stop", stopGeneration)                                // 停止生成（跨实例）
+
+		group.POST("/regenerate/:message_id", middleware.QuotaMiddleware(), streamRegenerateMessage)    // 流式重新生成回复
+		group.POST("/regenerate/:message_id/complete", middleware.QuotaMiddleware(), regenerateMessage) // 非流式重新生成回复
+		group.POST("/edit/:message_id", middleware.QuotaMiddleware(), streamEditMessage)                // 编辑用户消息并流式返回新分支的回复
+		group.POST("/edit/:message_id/complete", middleware.QuotaMiddleware(), editMessage)             // 编辑用户消息并非流式返回新分支的回复
 	}
 }
 
@@ -30,13 +42,223 @@ func streamSendMessage(c *gin.Context) {
 	}
 
 	var req *models.AskReq
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := c.ShouldBindJSON(&req); err != nil || (req.Message == "" && len(req.Parts) == 0) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
 		return
 	}
 
 	// 流式处理消息并返回 SSE
-	if err := services.StreamSendMessage(c, conversationID, req.Message); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	userID := utils.GetUserIDFromContext(c)
+	if err := services.StreamSendMessage(c, userID, conversationID, req); err != nil {
+		c.JSON(chatErrorStatus(err), chatErrorBody(err))
+	}
+}
+
+func sendMessage(c *gin.Context) {
+	conversationIDStr := c.Param("conversation_id")
+
+	// 将字符串转换为 int64
+	conversationID, err := strconv.ParseInt(conversationIDStr, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid conversation ID"})
+		return
+	}
+
+	var req *models.AskReq
+	if err := c.ShouldBindJSON(&req); err != nil || (req.Message == "" && len(req.Parts) == 0) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	// 等待完整回复后以 JSON 返回
+	userID := utils.GetUserIDFromContext(c)
+	resp, err := services.SendMessage(c.Request.Context(), userID, conversationID, req)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), chatErrorBody(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func streamRegenerateMessage(c *gin.Context) {
+	conversationID, messageID, ok := messageTarget(c)
+	if !ok {
+		return
+	}
+
+	// 请求体可省略，仅用于覆盖生成参数
+	var req models.RegenerateReq
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+	}
+
+	userID := utils.GetUserIDFromContext(c)
+	if err := services.StreamRegenerateMessage(c, userID, conversationID, messageID, &req); err != nil {
+		c.JSON(chatErrorStatus(err), chatErrorBody(err))
+	}
+}
+
+func regenerateMessage(c *gin.Context) {
+	conversationID, messageID, ok := messageTarget(c)
+	if !ok {
+		return
+	}
+
+	var req models.RegenerateReq
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+	}
+
+	userID := utils.GetUserIDFromContext(c)
+	resp, err := services.RegenerateMessage(c.Request.Context(), userID, conversationID, messageID, &req)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), chatErrorBody(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func streamEditMessage(c *gin.Context) {
+	conversationID, messageID, ok := messageTarget(c)
+	if !ok {
+		return
+	}
+
+	var req *models.AskReq
+	if err := c.ShouldBindJSON(&req); err != nil || (req.Message == "" && len(req.Parts) == 0) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	userID := utils.GetUserIDFromContext(c)
+	if err := services.StreamEditMessage(c, userID, conversationID, messageID, req); err != nil {
+		c.JSON(chatErrorStatus(err), chatErrorBody(err))
+	}
+}
+
+func editMessage(c *gin.Context) {
+	conversationID, messageID, ok := messageTarget(c)
+	if !ok {
+		return
+	}
+
+	var req *models.AskReq
+	if err := c.ShouldBindJSON(&req); err != nil || (req.Message == "" && len(req.Parts) == 0) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	userID := utils.GetUserIDFromContext(c)
+	resp, err := services.EditMessage(c.Request.Context(), userID, conversationID, messageID, req)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), chatErrorBody(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// messageTarget 解析会话 ID 与要重新生成或编辑的消息 ID，失败时已写入响应
+func messageTarget(c *gin.Context) (int64, int32, bool) {
+	conversationID, err := strconv.ParseInt(c.Param("conversation_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid conversation ID"})
+		return 0, 0, false
+	}
+	messageID, err := strconv.ParseInt(c.Param("message_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid message ID"})
+		return 0, 0, false
+	}
+	return conversationID, int32(messageID), true
+}
+
+func resumeStream(c *gin.Context) {
+	conversationIDStr := c.Param("conversation_id")
+
+	// 将字符串转换为 int64
+	conversationID, err := strconv.ParseInt(conversationIDStr, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid conversation ID"})
+		return
+	}
+
+	// 标准 SSE 客户端重连时通过 Last-Event-ID 头携带最后收到的事件 ID
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+
+	userID := utils.GetUserIDFromContext(c)
+	if err := services.ResumeStream(c, userID, conversationID, c.Query("generation_id"), lastEventID); err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": err.Error()})
+	}
+}
+
+func stopGeneration(c *gin.Context) {
+	conversationIDStr := c.Param("conversation_id")
+
+	// 将字符串转换为 int64
+	conversationID, err := strconv.ParseInt(conversationIDStr, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid conversation ID"})
+		return
+	}
+
+	// generation_id 为空时停止会话中全部进行中的生成
+	userID := utils.GetUserIDFromContext(c)
+	if err := services.StopGeneration(userID, conversationID, c.Query("generation_id")); err != nil {
+		c.JSON(chatErrorStatus(err), chatErrorBody(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Stop signal sent"})
+}
+
+// chatErrorBody 错误响应体，降级链全部失败时附带每个模型的失败原因
+func chatErrorBody(err error) gin.H {
+	body := gin.H{"error": err.Error()}
+	var fallbackErr *services.FallbackError
+	if errors.As(err, &fallbackErr) {
+		body["attempts"] = fallbackErr.Attempts
+	}
+	return body
+}
+
+// chatErrorStatus 模型、生成参数、知识库或消息片段校验失败属于请求错误，其余按服务端错误处理
+func chatErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, providers.ErrInvalidParams),
+		errors.Is(err, providers.ErrUnsupportedModel),
+		errors.Is(err, services.ErrKnowledgeBaseNotFound),
+		errors.Is(err, services.ErrInvalidContent),
+		errors.Is(err, services.ErrFileNotFound),
+		errors.Is(err, services.ErrFileTooLarge),
+		errors.Is(err, services.ErrNothingToSummarize),
+		errors.Is(err, services.ErrInvalidReport),
+		errors.Is(err, services.ErrInvalidQuota):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrUserNotFound),
+		errors.Is(err, services.ErrConversationNotFound),
+		errors.Is(err, services.ErrMessageNotFound),
+		errors.Is(err, services.ErrNoActiveGeneration),
+		errors.Is(err, services.ErrGenerationNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrReportForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrMemoryBusy),
+		errors.Is(err, services.ErrGenerationStopped),
+		errors.Is(err, services.ErrConversationBusy),
+		errors.Is(err, services.ErrConversationLockLost):
+		return http.StatusConflict
 	}
+	return http.StatusInternalServerError
 }
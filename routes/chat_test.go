@@ -0,0 +1,28 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/EthanGuo-coder/llm-backend-api/services"
+)
+
+func TestChatErrorStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{services.ErrConversationBusy, http.StatusConflict},
+		{fmt.Errorf("failed to append user message: %w", services.ErrConversationLockLost), http.StatusConflict},
+		{services.ErrGenerationStopped, http.StatusConflict},
+		{services.ErrConversationNotFound, http.StatusNotFound},
+		{errors.New("redis unavailable"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		if got := chatErrorStatus(tt.err); got != tt.want {
+			t.Errorf("chatErrorStatus(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
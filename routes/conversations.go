@@ -1,6 +1,7 @@
 package routes
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -14,12 +15,19 @@ import (
 
 func RegisterConversationRoutes(r *gin.Engine) {
 	group := r.Group("/api/conversations")
+	group.Use(middleware.AuthMiddleware(), middleware.RateLimitMiddleware("conversations"))
 	{
-		group.POST("/create", middleware.AuthMiddleware(), createConversation)                      // 创建新会话
-		group.GET("/history/:conversation_id", middleware.AuthMiddleware(), getConversationHistory) // 用户单会话对话记录
-
-		group.GET("/list", middleware.AuthMiddleware(), getUserConversations)                // 用户会话列表
-		group.POST("/del/:conversation_id", middleware.AuthMiddleware(), deleteConversation) // 删除用户会话（某一个）
+		group.POST("/create", createConversation)                                              // 创建新会话
+		group.GET("/history/:conversation_id", getConversationHistory)                         // 用户单会话对话记录
+		group.POST("/params/:conversation_id", updateConversationParams)                       // 更新会话默认生成参数
+		group.POST("/kbs/:conversation_id", updateConversationKBs)                             // 更新会话关联知识库
+		group.GET("/memory/:conversation_id", getConversationMemory)                           // 查看会话滚动摘要
+		group.POST("/memory/:conversation_id", middleware.QuotaMiddleware(), regenerateMemory) // 重新生成会话滚动摘要，调用模型因此计入配额
+		group.POST("/branch/:conversation_id", switchBranch)                                   // 切换会话选中的分支
+		group.POST("/fork/:conversation_id", forkConversation)                                 // 复制会话到指定消息为新会话
+
+		group.GET("/list", getUserConversations)                // 用户会话列表
+		group.POST("/del/:conversation_id", deleteConversation) // 删除用户会话（某一个）
 	}
 }
 
@@ -39,9 +47,9 @@ func createConversation(c *gin.Context) {
 	}
 
 	// 创建新会话
-	conversation, err := services.CreateConversation(userID, req.Title, req.Model, req.ApiKey)
+	conversation, err := services.CreateConversation(userID, req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(chatErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -65,6 +73,144 @@ func getConversationHistory(c *gin.Context) {
 	c.JSON(http.StatusOK, history)
 }
 
+func updateConversationParams(c *gin.Context) {
+	conversationIDStr := c.Param("conversation_id")
+	// 将字符串转换为 int64
+	conversationID, err := strconv.ParseInt(conversationIDStr, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid conversation ID"})
+		return
+	}
+
+	var req *models.UpdateParamsReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	userID := utils.GetUserIDFromContext(c)
+	params, err := services.UpdateConversationParams(userID, conversationID, req.Params)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"params": params})
+}
+
+func updateConversationKBs(c *gin.Context) {
+	conversationIDStr := c.Param("conversation_id")
+	// 将字符串转换为 int64
+	conversationID, err := strconv.ParseInt(conversationIDStr, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid conversation ID"})
+		return
+	}
+
+	var req *models.UpdateKnowledgeBasesReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	userID := utils.GetUserIDFromContext(c)
+	kbIDs, err := services.UpdateConversationKnowledgeBases(userID, conversationID, req.KBIDs)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"kb_ids": kbIDs})
+}
+
+func getConversationMemory(c *gin.Context) {
+	conversationIDStr := c.Param("conversation_id")
+	// 将字符串转换为 int64
+	conversationID, err := strconv.ParseInt(conversationIDStr, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid conversation ID"})
+		return
+	}
+
+	userID := utils.GetUserIDFromContext(c)
+	memory, err := services.GetConversationMemory(userID, conversationID)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"memory": memory})
+}
+
+func regenerateMemory(c *gin.Context) {
+	conversationIDStr := c.Param("conversation_id")
+	// 将字符串转换为 int64
+	conversationID, err := strconv.ParseInt(conversationIDStr, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid conversation ID"})
+		return
+	}
+
+	userID := utils.GetUserIDFromContext(c)
+	memory, err := services.RegenerateConversationMemory(c.Request.Context(), userID, conversationID)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"memory": memory})
+}
+
+func switchBranch(c *gin.Context) {
+	conversationIDStr := c.Param("conversation_id")
+	// 将字符串转换为 int64
+	conversationID, err := strconv.ParseInt(conversationIDStr, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid conversation ID"})
+		return
+	}
+
+	var req models.SwitchBranchReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	userID := utils.GetUserIDFromContext(c)
+	history, err := services.SwitchBranch(userID, conversationID, *req.MessageID)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
+
+func forkConversation(c *gin.Context) {
+	conversationIDStr := c.Param("conversation_id")
+	// 将字符串转换为 int64
+	conversationID, err := strconv.ParseInt(conversationIDStr, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid conversation ID"})
+		return
+	}
+
+	var req models.ForkConversationReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	userID := utils.GetUserIDFromContext(c)
+	conversation, err := services.ForkConversation(userID, conversationID, &req)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, conversation)
+}
+
 func getUserConversations(c *gin.Context) {
 	userID := utils.GetUserIDFromContext(c)
 	conversations, err := services.GetUserConversations(userID)
@@ -88,7 +234,11 @@ func deleteConversation(c *gin.Context) {
 	userID := utils.GetUserIDFromContext(c)
 
 	if err := services.DeleteUserConversation(userID, conversationID); err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		status := http.StatusNotFound
+		if errors.Is(err, services.ErrConversationBusy) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -0,0 +1,101 @@
+package routes
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/EthanGuo-coder/llm-backend-api/middleware"
+	"github.com/EthanGuo-coder/llm-backend-api/services"
+	"github.com/EthanGuo-coder/llm-backend-api/utils"
+)
+
+// RegisterFileRoutes 注册文件上传与下载路由
+func RegisterFileRoutes(r *gin.Engine) {
+	group := r.Group("/api/files")
+	group.Use(middleware.AuthMiddleware(), middleware.RateLimitMiddleware("files"))
+	{
+		group.POST("/upload", uploadFile)                   // 上传图片或文件
+		group.GET("/:file_id", downloadFile)                // 下载原文件
+		group.GET("/:file_id/thumbnail", downloadThumbnail) // 下载缩略图
+	}
+}
+
+// multipartOverhead 请求体中除文件内容外的 multipart 边界与字段所占空间
+const multipartOverhead = 1 << 20
+
+func uploadFile(c *gin.Context) {
+	// 限制请求体大小，超大的上传在解析 multipart 时即被拒绝，不会写满临时目录
+	if maxSize := services.MaxFileSize(); maxSize > 0 {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize+multipartOverhead)
+	}
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": services.ErrFileTooLarge.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No file uploaded"})
+		return
+	}
+	if err := services.CheckFileSize(fileHeader.Size); err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open file"})
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file"})
+		return
+	}
+
+	userID := utils.GetUserIDFromContext(c)
+	resp, err := services.UploadFile(userID, fileHeader.Filename, data)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func downloadFile(c *gin.Context) {
+	serveFile(c, false)
+}
+
+func downloadThumbnail(c *gin.Context) {
+	serveFile(c, true)
+}
+
+// serveFile 返回当前用户的文件内容
+func serveFile(c *gin.Context, thumbnail bool) {
+	userID := utils.GetUserIDFromContext(c)
+	file, data, err := services.GetFile(userID, c.Param("file_id"), thumbnail)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrFileNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Cache-Control", "private, max-age=86400")
+	// 禁止浏览器猜测类型；位图以外的文件（如 HTML、SVG）只作为附件下载，避免在 API 源下执行脚本
+	c.Header("X-Content-Type-Options", "nosniff")
+	if !services.IsRasterImage(file.MimeType) {
+		c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": file.Filename}))
+	}
+	c.Data(http.StatusOK, file.MimeType, data)
+}
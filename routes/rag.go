@@ -18,7 +18,7 @@ import (
 // RegisterRagRoutes 注册 RAG 相关路由
 func RegisterRagRoutes(r *gin.Engine) {
 	group := r.Group("/api/rag")
-	group.Use(middleware.AuthMiddleware())
+	group.Use(middleware.AuthMiddleware(), middleware.RateLimitMiddleware("rag"))
 	{
 		// 知识库管理
 		group.POST("/kb/create", createKnowledgeBase)
@@ -33,8 +33,8 @@ func RegisterRagRoutes(r *gin.Engine) {
 		// 检索功能
 		group.POST("/retrieve", retrieveInfo)
 
-		// 基于知识库的对话
-		group.POST("/chat", ragChat)
+		// 基于知识库的对话，同时计入 chat 组的限流
+		group.POST("/chat", middleware.RateLimitMiddleware("chat"), middleware.QuotaMiddleware(), ragChat)
 
 		// 元数据
 		group.GET("/models", listEmbeddingModels)
@@ -307,7 +307,7 @@ func retrieveInfo(c *gin.Context) {
 	}
 
 	// 调用 RAG 服务检索信息
-	resp, err := ragService.RetrieveInfo(req.KBID, req.Query, req.TopK)
+	resp, err := ragService.RetrieveInfo(c.Request.Context(), req.KBID, req.Query, req.TopK)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -343,16 +343,20 @@ func ragChat(c *gin.Context) {
 		return
 	}
 
-	// 生成基于知识库的提示
-	prompt, err := ragService.GenerateRagPrompt(req.KBID, req.Message, req.TopK)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成提示失败: " + err.Error()})
-		return
+	// 会话已关联该知识库且启用了检索工具时，由模型决定是否检索；否则把检索结果拼入提示
+	message := req.Message
+	if !services.UsesKnowledgeBaseTool(req.ConversationID, req.KBID) {
+		message, err = ragService.GenerateRagPrompt(c.Request.Context(), req.KBID, req.Message, req.TopK)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "生成提示失败: " + err.Error()})
+			return
+		}
 	}
 
 	// 使用提示进行对话
-	if err := services.StreamSendMessage(c, req.ConversationID, prompt); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	askReq := &models.AskReq{Message: message, Params: req.Params}
+	if err := services.StreamSendMessage(c, userID, req.ConversationID, askReq); err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 }
@@ -15,4 +15,13 @@ func RegisterRoutes(r *gin.Engine) {
 
 	// RAG 相关路由
 	RegisterRagRoutes(r)
+
+	// 文件相关路由
+	RegisterFileRoutes(r)
+
+	// 用量相关路由
+	RegisterUsageRoutes(r)
+
+	// 管理员路由
+	RegisterAdminRoutes(r)
 }
@@ -0,0 +1,92 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/EthanGuo-coder/llm-backend-api/middleware"
+	"github.com/EthanGuo-coder/llm-backend-api/services"
+	"github.com/EthanGuo-coder/llm-backend-api/utils"
+)
+
+// RegisterUsageRoutes 注册用量查询路由
+func RegisterUsageRoutes(r *gin.Engine) {
+	group := r.Group("/api/usage")
+	group.Use(middleware.AuthMiddleware(), middleware.RateLimitMiddleware("usage"))
+	{
+		group.GET("", getUserUsage)                                        // 用户用量总计与按会话明细
+		group.GET("/conversations/:conversation_id", getConversationUsage) // 单个会话用量
+		group.GET("/report", getUsageReport)                               // 按用户、模型、天汇总的费用报表，支持 CSV 导出
+		group.GET("/quota", getMyQuota)                                    // 当前用户的配额与已用额度
+	}
+}
+
+func getUserUsage(c *gin.Context) {
+	userID := utils.GetUserIDFromContext(c)
+	usage, err := services.GetUserUsage(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, usage)
+}
+
+func getConversationUsage(c *gin.Context) {
+	conversationID, err := strconv.ParseInt(c.Param("conversation_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid conversation ID"})
+		return
+	}
+
+	userID := utils.GetUserIDFromContext(c)
+	usage, err := services.GetConversationUsage(userID, conversationID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"conversation_id": conversationID, "usage": usage})
+}
+
+func getUsageReport(c *gin.Context) {
+	var targetUserID int64
+	if value := c.Query("user_id"); value != "" {
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+		targetUserID = id
+	}
+
+	userID := utils.GetUserIDFromContext(c)
+	report, err := services.GetUsageReport(userID, c.Query("group_by"), c.Query("from"), c.Query("to"), targetUserID)
+	if err != nil {
+		c.JSON(chatErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	if c.Query("format") != "csv" {
+		c.JSON(http.StatusOK, report)
+		return
+	}
+	filename := fmt.Sprintf("usage-report-%s-%s.csv", report.From, report.To)
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Status(http.StatusOK)
+	if err := services.WriteReportCSV(c.Writer, report); err != nil {
+		c.Error(err)
+	}
+}
+
+func getMyQuota(c *gin.Context) {
+	userID := utils.GetUserIDFromContext(c)
+	status, err := services.GetQuotaStatus(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
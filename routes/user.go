@@ -5,11 +5,13 @@ import (
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/EthanGuo-coder/llm-backend-api/middleware"
 	"github.com/EthanGuo-coder/llm-backend-api/services"
 )
 
 func RegisterUserRoutes(r *gin.Engine) {
 	group := r.Group("/api/users")
+	group.Use(middleware.RateLimitMiddleware("auth"))
 	{
 		group.POST("/register", registerUser)
 		group.POST("/login", loginUser)
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/EthanGuo-coder/llm-backend-api/billing"
+	"github.com/EthanGuo-coder/llm-backend-api/constant"
+	"github.com/EthanGuo-coder/llm-backend-api/models"
+	"github.com/EthanGuo-coder/llm-backend-api/storage"
+)
+
+// reportDateLayout 报表日期格式，按 UTC 计算
+const reportDateLayout = "2006-01-02"
+
+// defaultReportDays 未指定起始日期时统计的天数
+const defaultReportDays = 30
+
+// reportDimensions 报表的全部分组维度，按输出顺序排列
+var reportDimensions = []string{constant.ReportByDay, constant.ReportByUser, constant.ReportByModel, constant.ReportByKind}
+
+var (
+	// ErrInvalidReport 报表查询条件不合法
+	ErrInvalidReport = errors.New("invalid report query")
+	// ErrReportForbidden 非管理员查询其他用户的报表
+	ErrReportForbidden = errors.New("only billing admins can view other users' reports")
+)
+
+// GetUsageReport 生成费用报表。groupBy 为逗号分隔的 day、user、model、kind，留空时全部分组；
+// from、to 为 UTC 日期（含），默认最近 30 天。管理员可查看全部用户或指定 targetUserID，其他用户只能查看自己
+func GetUsageReport(userID int64, groupBy, from, to string, targetUserID int64) (*models.ReportResp, error) {
+	query := &models.ReportQuery{UserID: targetUserID}
+	if !billing.IsAdmin(userID) {
+		if targetUserID != 0 && targetUserID != userID {
+			return nil, ErrReportForbidden
+		}
+		query.UserID = userID
+	}
+
+	query.GroupBy = slices.Clone(reportDimensions)
+	if groupBy != "" {
+		query.GroupBy = nil
+		for _, dimension := range strings.Split(groupBy, ",") {
+			dimension = strings.TrimSpace(dimension)
+			switch dimension {
+			case constant.ReportByDay, constant.ReportByUser, constant.ReportByModel, constant.ReportByKind:
+				if !slices.Contains(query.GroupBy, dimension) {
+					query.GroupBy = append(query.GroupBy, dimension)
+				}
+			default:
+				return nil, fmt.Errorf("%w: unknown group_by %q", ErrInvalidReport, dimension)
+			}
+		}
+	}
+
+	start, end, err := reportRange(from, to)
+	if err != nil {
+		return nil, err
+	}
+	query.From, query.To = start.Unix(), end.AddDate(0, 0, 1).Unix()
+
+	rows, err := storage.GetUsageReportFromDB(query)
+	if err != nil {
+		return nil, err
+	}
+	report := &models.ReportResp{
+		From:    start.Format(reportDateLayout),
+		To:      end.Format(reportDateLayout),
+		GroupBy: query.GroupBy,
+		Rows:    rows,
+	}
+	for _, row := range rows {
+		report.Total.Requests += row.Requests
+		report.Total.PromptTokens += row.PromptTokens
+		report.Total.CompletionTokens += row.CompletionTokens
+		report.Total.TotalTokens += row.TotalTokens
+		report.Total.Cost += row.Cost
+	}
+	return report, nil
+}
+
+// reportRange 解析报表日期范围，返回起止日期的零点（UTC）
+func reportRange(from, to string) (time.Time, time.Time, error) {
+	end := time.Now().UTC().Truncate(24 * time.Hour)
+	if to != "" {
+		parsed, err := time.Parse(reportDateLayout, to)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("%w: to must be a date like 2024-01-31", ErrInvalidReport)
+		}
+		end = parsed
+	}
+	start := end.AddDate(0, 0, -(defaultReportDays - 1))
+	if from != "" {
+		parsed, err := time.Parse(reportDateLayout, from)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("%w: from must be a date like 2024-01-01", ErrInvalidReport)
+		}
+		start = parsed
+	}
+	if start.After(end) {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: from is after to", ErrInvalidReport)
+	}
+	return start, end, nil
+}
+
+// WriteReportCSV 将报表明细写为 CSV，列为参与分组的维度加各项用量
+func WriteReportCSV(w io.Writer, report *models.ReportResp) error {
+	var header []string
+	for _, dimension := range reportDimensions {
+		if !slices.Contains(report.GroupBy, dimension) {
+			continue
+		}
+		switch dimension {
+		case constant.ReportByDay:
+			header = append(header, "day")
+		case constant.ReportByUser:
+			header = append(header, "user_id", "username")
+		case constant.ReportByModel:
+			header = append(header, "provider", "model")
+		case constant.ReportByKind:
+			header = append(header, "kind")
+		}
+	}
+	header = append(header, "requests", "prompt_tokens", "completion_tokens", "total_tokens", "cost_usd")
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	for _, row := range report.Rows {
+		var record []string
+		for _, column := range header {
+			switch column {
+			case "day":
+				record = append(record, row.Day)
+			case "user_id":
+				record = append(record, strconv.FormatInt(row.UserID, 10))
+			case "username":
+				record = append(record, row.Username)
+			case "provider":
+				record = append(record, row.Provider)
+			case "model":
+				record = append(record, row.Model)
+			case "kind":
+				record = append(record, row.Kind)
+			}
+		}
+		record = append(record,
+			strconv.FormatInt(row.Requests, 10),
+			strconv.FormatInt(row.PromptTokens, 10),
+			strconv.FormatInt(row.CompletionTokens, 10),
+			strconv.FormatInt(row.TotalTokens, 10),
+			strconv.FormatFloat(row.Cost, 'f', 6, 64),
+		)
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
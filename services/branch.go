@@ -0,0 +1,129 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/EthanGuo-coder/llm-backend-api/models"
+	"github.com/EthanGuo-coder/llm-backend-api/storage"
+)
+
+// StreamEditMessage 流式处理编辑后的用户消息：在原消息的父消息下新建分支并生成回复
+func StreamEditMessage(c *gin.Context, userID, conversationID int64, messageID int32, req *models.AskReq) error {
+	// 同一会话同时只允许一个请求读改写，生成结束前一直持有
+	lock, err := lockConversation(conversationID)
+	if err != nil {
+		return err
+	}
+	defer lock.unlock()
+
+	conversation, params, err := prepareEdit(lock, userID, conversationID, messageID, req)
+	if err != nil {
+		return err
+	}
+	return streamAnswer(c, lock, userID, conversation, params)
+}
+
+// EditMessage 非流式处理编辑后的用户消息：在原消息的父消息下新建分支并生成回复
+func EditMessage(ctx context.Context, userID, conversationID int64, messageID int32, req *models.AskReq) (*models.ChatResponse, error) {
+	// 同一会话同时只允许一个请求读改写，生成结束前一直持有
+	lock, err := lockConversation(conversationID)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.unlock()
+
+	conversation, params, err := prepareEdit(lock, userID, conversationID, messageID, req)
+	if err != nil {
+		return nil, err
+	}
+	return completeAnswer(ctx, lock, userID, conversation, params)
+}
+
+// prepareEdit 将选中分支截断到 messageID 之前，并追加编辑后的用户消息，原消息及其后续消息留在原分支中
+func prepareEdit(lock *conversationLock, userID, conversationID int64, messageID int32, req *models.AskReq) (*models.Conversation, *models.GenerationParams, error) {
+	conversation, params, err := loadConversation(lock, userID, conversationID, req.Params)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	index := -1
+	for i, message := range conversation.Messages {
+		if message.MessageID == messageID && message.Role == "user" {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return nil, nil, fmt.Errorf("%w: no user message %d in the selected branch", ErrMessageNotFound, messageID)
+	}
+
+	conversation.Messages = conversation.Messages[:index]
+	if err := appendUserMessage(userID, conversation, req); err != nil {
+		return nil, nil, err
+	}
+	return conversation, params, nil
+}
+
+// SwitchBranch 选中 userID 的会话中包含 messageID 的分支。messageID 之后有多个分支时沿最新的分支走到末尾，
+// 会话不存在或属于他人时返回 ErrConversationNotFound
+func SwitchBranch(userID, conversationID int64, messageID int32) (*models.ConversationHistory, error) {
+	// 生成进行中时不能切换分支，否则生成结束时的保存会覆盖切换
+	lock, err := lockConversation(conversationID)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.unlock()
+
+	conversation, err := getUserConversation(userID, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	lock.attach(conversation)
+
+	found := false
+	for _, message := range conversation.Tree {
+		if message.MessageID == messageID {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("%w: no message %d", ErrMessageNotFound, messageID)
+	}
+
+	conversation.Messages = storage.MessagePath(conversation.Tree, latestLeaf(conversation.Tree, messageID))
+	if err := saveConversation(conversation); err != nil {
+		return nil, fmt.Errorf("failed to save conversation: %w", err)
+	}
+	return conversationHistory(conversation), nil
+}
+
+// latestLeaf 从 messageID 开始每次进入最新创建的子消息，返回到达的末尾消息
+func latestLeaf(tree []models.Message, messageID int32) int32 {
+	children := childIDs(tree)
+	leafID := messageID
+	// 路径长度不超过消息总数，避免损坏的父子关系导致死循环
+	for range tree {
+		next := children[leafID]
+		if len(next) == 0 {
+			break
+		}
+		leafID = next[len(next)-1]
+	}
+	return leafID
+}
+
+// childIDs 按父消息 ID 分组子消息 ID，组内按创建顺序排列；根消息（首条消息）不属于任何分组
+func childIDs(tree []models.Message) map[int32][]int32 {
+	children := make(map[int32][]int32)
+	for i, message := range tree {
+		if i == 0 {
+			continue
+		}
+		children[message.ParentID] = append(children[message.ParentID], message.MessageID)
+	}
+	return children
+}
@@ -1,214 +1,422 @@
 package services
 
 import (
-	"bufio"
-	"bytes"
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/EthanGuo-coder/llm-backend-api/billing"
+	"github.com/EthanGuo-coder/llm-backend-api/config"
 	"github.com/EthanGuo-coder/llm-backend-api/models"
-	"github.com/EthanGuo-coder/llm-backend-api/storage"
-	"github.com/EthanGuo-coder/llm-backend-api/utils"
+	"github.com/EthanGuo-coder/llm-backend-api/providers"
+	"github.com/EthanGuo-coder/llm-backend-api/tools"
 )
 
 // StreamSendMessage 处理流式消息发送
-func StreamSendMessage(c *gin.Context, conversationID int64, message string) error {
-	// 获取会话
-	conversation, err := getConversationWithMessage(conversationID, message)
-	if err != nil {
-		return err
-	}
-	// 构造请求体
-	requestData, err := buildRequestBody(conversation)
+func StreamSendMessage(c *gin.Context, userID, conversationID int64, req *models.AskReq) error {
+	// 同一会话同时只允许一个请求读改写，生成结束前一直持有
+	lock, err := lockConversation(conversationID)
 	if err != nil {
 		return err
 	}
-	// 使用存储的 api_key
-	apiKey := conversation.ApiKey
-	// 创建 HTTP 请求
-	resp, err := sendAPIRequest(apiKey, requestData, conversation.Model)
+	defer lock.unlock()
+
+	// 获取会话
+	conversation, params, err := getConversationWithMessage(lock, userID, conversationID, req)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
-	// 检查响应状态码
-	if err := validateResponse(resp); err != nil {
-		return err
+	return streamAnswer(c, lock, userID, conversation, params)
+}
+
+// streamAnswer 为会话最后一条用户消息生成回复并以 SSE 推送；
+// 开始推送后的错误以 error 事件发送，返回的错误均发生在写入响应之前
+func streamAnswer(c *gin.Context, lock *conversationLock, userID int64, conversation *models.Conversation, params *models.GenerationParams) error {
+	// 上游请求与客户端连接绑定，客户端断开时取消生成；
+	// 可续传模式（resumable=true）下生成与连接解绑，断线后可通过续传接口取回剩余内容
+	ctx := c.Request.Context()
+	if c.Query("resumable") == "true" {
+		ctx = context.WithoutCancel(ctx)
 	}
-	// 设置 SSE 响应头
-	setSSEHeaders(c)
-	// 处理流式响应
-	fullResponse, err := handleSSEStream(c, resp.Body)
+	// 任意实例收到停止请求时通过 Redis Pub/Sub 取消本次生成
+	generationID := newGenerationID()
+	ctx, release := watchStop(ctx, conversation.ID, generationID)
+	defer release()
+	// 会话写锁丢失时中止生成，此时会话可能已被其他请求修改
+	ctx, unbind := lock.bind(ctx)
+	defer unbind()
+	// 建立上游连接，临时错误先重试，仍失败时按降级链切换模型
+	files := fileCache{}
+	up, events, err := openUpstream(ctx, conversation, params, files)
 	if err != nil {
-		return err
+		return stopCause(ctx, err)
 	}
-	// 保存完整的会话到 Redis
-	if err := saveConversationWithAIResponse(conversation, fullResponse); err != nil {
-		return err
+	// 设置 SSE 响应头并协商输出格式，同时把事件记录到 Redis Stream 以便续传
+	w := newSSEWriter(c)
+	w.record(conversation.ID, generationID)
+	defer w.close()
+	// 处理流式响应，模型调用工具时在服务端执行并继续生成
+	result, err := generate(ctx, w, conversation, params, files, up, events)
+	// 保存完整的会话到 Redis，中断或停止时保存部分回复
+	if err := saveAnswer(userID, conversation, result, stopCause(ctx, err)); err != nil {
+		if !errors.Is(err, ErrGenerationStopped) {
+			// 响应头已按 SSE 发出，错误只能作为事件推送
+			log.Printf("conversation %d: generation %s failed: %v", conversation.ID, generationID, err)
+			w.send("error", err.Error())
+			return nil
+		}
+		scheduleMemoryUpdate(conversation)
+		w.send("stopped", result.content)
+		return nil
 	}
+	// 历史变长后在后台更新滚动摘要
+	scheduleMemoryUpdate(conversation)
 	// 发送完成消息
-	sendStreamEndMessage(c, fullResponse)
+	sendStreamEndMessage(w, result.content)
 
 	return nil
 }
 
-// getConversationWithMessage 获取会话并添加用户消息
-func getConversationWithMessage(conversationID int64, message string) (*models.Conversation, error) {
+// SendMessage 处理非流式消息发送，在服务端汇总上游流后一次性返回完整回复
+func SendMessage(ctx context.Context, userID, conversationID int64, req *models.AskReq) (*models.ChatResponse, error) {
+	// 同一会话同时只允许一个请求读改写，生成结束前一直持有
+	lock, err := lockConversation(conversationID)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.unlock()
+
+	// 获取会话
+	conversation, params, err := getConversationWithMessage(lock, userID, conversationID, req)
+	if err != nil {
+		return nil, err
+	}
+	return completeAnswer(ctx, lock, userID, conversation, params)
+}
+
+// completeAnswer 为会话最后一条用户消息生成回复，汇总后一次性返回
+func completeAnswer(ctx context.Context, lock *conversationLock, userID int64, conversation *models.Conversation, params *models.GenerationParams) (*models.ChatResponse, error) {
+	// 非流式请求没有生成 ID，只响应针对整个会话的停止信号
+	ctx, release := watchStop(ctx, conversation.ID, "")
+	defer release()
+	// 会话写锁丢失时中止生成，此时会话可能已被其他请求修改
+	ctx, unbind := lock.bind(ctx)
+	defer unbind()
+	// 建立上游连接，临时错误先重试，仍失败时按降级链切换模型
+	files := fileCache{}
+	up, events, err := openUpstream(ctx, conversation, params, files)
+	if err != nil {
+		return nil, stopCause(ctx, err)
+	}
+	// 汇总上游流，被停止时返回已生成的部分回复
+	result, err := generate(ctx, nil, conversation, params, files, up, events)
+	err = saveAnswer(userID, conversation, result, stopCause(ctx, err))
+	if err != nil && !errors.Is(err, ErrGenerationStopped) {
+		return nil, err
+	}
+	scheduleMemoryUpdate(conversation)
+
+	resp := &models.ChatResponse{
+		ConversationID: conversation.ID,
+		FinishReason:   result.finishReason,
+		Usage:          result.usage,
+	}
+	if err != nil {
+		resp.FinishReason = "stopped"
+	}
+	// 停止前没有收到内容时不保存回复，最后一条仍是用户消息
+	if aiMessage := conversation.Messages[len(conversation.Messages)-1]; aiMessage.Role == "assistant" {
+		resp.MessageID = aiMessage.MessageID
+		resp.Message = aiMessage
+	}
+	return resp, nil
+}
+
+// getConversationWithMessage 获取会话并添加用户消息，同时返回本次生成实际使用的参数
+func getConversationWithMessage(lock *conversationLock, userID, conversationID int64, req *models.AskReq) (*models.Conversation, *models.GenerationParams, error) {
 	// 从 Redis 获取会话
-	conversation, err := storage.GetConversationFromRedis(conversationID)
+	conversation, params, err := loadConversation(lock, userID, conversationID, req.Params)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := appendUserMessage(userID, conversation, req); err != nil {
+		return nil, nil, err
+	}
+	return conversation, params, nil
+}
+
+// loadConversation 获取 userID 的会话，不存在或属于他人时返回 ErrConversationNotFound；
+// 之后的保存以 lock 为条件，并以 override 覆盖会话默认参数得到本次生成的参数
+func loadConversation(lock *conversationLock, userID, conversationID int64, override *models.GenerationParams) (*models.Conversation, *models.GenerationParams, error) {
+	conversation, err := getUserConversation(userID, conversationID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load conversation: %v", err)
+		return nil, nil, err
 	}
-	if conversation == nil {
-		return nil, fmt.Errorf("conversation not found")
+	lock.attach(conversation)
+	// 请求参数覆盖会话默认参数，校验失败时不写入用户消息
+	params := mergeParams(conversation.Params, override)
+	if err := validateParams(conversation.Model, params); err != nil {
+		return nil, nil, err
 	}
-	// 追加用户消息
+	return conversation, params, nil
+}
+
+// appendUserMessage 在选中分支末尾追加用户消息并保存
+func appendUserMessage(userID int64, conversation *models.Conversation, req *models.AskReq) error {
+	// 追加用户消息，多模态片段中的内联图片先转存为文件
 	userMessage := models.Message{
 		Role:      "user",
-		Content:   message,
-		MessageID: int32(len(conversation.Messages)),
+		Content:   req.Message,
+		MessageID: nextMessageID(conversation),
+	}
+	if len(req.Parts) > 0 {
+		var err error
+		userMessage.Parts, userMessage.Content, err = normalizeParts(userID, req.Message, req.Parts)
+		if err != nil {
+			return err
+		}
 	}
 	conversation.Messages = append(conversation.Messages, userMessage)
 
 	// 将用户消息追加到 Redis
-	err = storage.SaveConversationToRedis(conversation)
-	if err != nil {
-		return nil, fmt.Errorf("failed to append user message: %v", err)
+	if err := saveConversation(conversation); err != nil {
+		return fmt.Errorf("failed to append user message: %w", err)
 	}
-
-	return conversation, nil
+	return nil
 }
 
-// buildRequestBody 构造 API 请求体
-func buildRequestBody(conversation *models.Conversation) ([]byte, error) {
-	requestBody := map[string]interface{}{
-		"model":    conversation.Model,
-		"messages": conversation.Messages,
-		"stream":   true,
+// buildRequestBody 构造发往 model 的请求体，已摘要的早期消息替换为滚动摘要，仍超出上下文窗口时按策略截断
+func buildRequestBody(ctx context.Context, conversation *models.Conversation, model, apiKey string, params *models.GenerationParams, files fileCache) *providers.ChatRequest {
+	definitions := tools.Definitions(conversation)
+	messages := fitContext(ctx, conversation, applyMemory(conversation), model, apiKey, params, definitions)
+	return &providers.ChatRequest{
+		Model:    model,
+		ApiKey:   apiKey,
+		Messages: resolveMessages(messages, files),
+		Params:   params,
+		Tools:    definitions,
 	}
-	return json.Marshal(requestBody)
 }
 
-// sendAPIRequest 发送 API 请求
-func sendAPIRequest(apiKey string, requestData []byte, model string) (*http.Response, error) {
-	client := &http.Client{}
-	baseURL, err := utils.GetBaseURL(model)
+// sendAPIRequest 通过服务商发送 API 请求，ctx 取消时上游请求随之中止
+func sendAPIRequest(ctx context.Context, provider providers.Provider, chatReq *providers.ChatRequest) (*http.Response, error) {
+	apiReq, err := provider.BuildRequest(ctx, chatReq)
 	if err != nil {
 		return nil, err
 	}
-	apiReq, err := http.NewRequest("POST", baseURL, bytes.NewBuffer(requestData))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	apiReq.Header.Set("Content-Type", "application/json")
-	apiReq.Header.Set("Authorization", "Bearer "+apiKey)
-
-	return client.Do(apiReq)
+	return provider.HTTPClient().Do(apiReq)
 }
 
 // validateResponse 验证 API 响应状态
-func validateResponse(resp *http.Response) error {
+func validateResponse(provider providers.Provider, resp *http.Response) error {
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("unexpected response status: %s", string(body))
+		return provider.MapError(resp)
 	}
 	return nil
 }
 
-// setSSEHeaders 设置 SSE 响应头
-func setSSEHeaders(c *gin.Context) {
-	c.Writer.Header().Set("Content-Type", "text/event-stream")
-	c.Writer.Header().Set("Cache-Control", "no-cache")
-	c.Writer.Header().Set("Connection", "keep-alive")
+// streamResult 一次生成的汇总结果
+type streamResult struct {
+	model        string
+	content      string
+	finishReason string
+	usage        *models.Usage
+	toolCalls    []models.ToolCall
+	toolIndex    map[int]int // 上游调用序号 -> toolCalls 下标
+	firstToken   time.Time   // 收到首个内容增量的时间
+	latency      time.Duration
+	ttft         time.Duration // 首 token 耗时，未收到内容时为 0
 }
 
-// handleSSEStream 处理流式 SSE 数据
-func handleSSEStream(c *gin.Context, body io.Reader) (string, error) {
-	reader := bufio.NewReader(body)
-	var fullResponse string
+// addToolCallDelta 按调用序号拼接流式工具调用片段
+func (r *streamResult) addToolCallDelta(delta providers.ToolCallDelta) {
+	if r.toolIndex == nil {
+		r.toolIndex = make(map[int]int)
+	}
+	i, ok := r.toolIndex[delta.Index]
+	if !ok {
+		r.toolCalls = append(r.toolCalls, models.ToolCall{})
+		i = len(r.toolCalls) - 1
+		r.toolIndex[delta.Index] = i
+	}
 
-	for {
-		line, err := reader.ReadBytes('\n')
-		if err != nil {
-			if err == io.EOF {
-				break
-			}
-			return "", fmt.Errorf("error reading stream: %w", err)
-		}
+	call := &r.toolCalls[i]
+	if delta.ID != "" {
+		call.ID = delta.ID
+	}
+	if delta.Name != "" {
+		call.Name = delta.Name
+	}
+	call.Arguments += delta.Arguments
+}
 
-		if len(line) == 0 || line[0] == ':' {
-			continue
+// generate 读取上游流；模型发起工具调用时执行工具并重新请求，直到给出最终回复。
+// w 为空时只汇总不推送，返回的用量为各轮之和，耗时从首次建立上游连接开始计算。
+func generate(ctx context.Context, w *sseWriter, conversation *models.Conversation, params *models.GenerationParams, files fileCache, up *upstream, events []upstreamEvent) (*streamResult, error) {
+	var usage *models.Usage
+	var firstToken time.Time
+	started := up.started
+	finish := func(result *streamResult) *streamResult {
+		result.usage = usage
+		result.latency = time.Since(started)
+		if !firstToken.IsZero() {
+			result.ttft = firstToken.Sub(started)
 		}
+		return result
+	}
 
-		if bytes.HasPrefix(line, []byte("data: ")) {
-			data := bytes.TrimPrefix(line, []byte("data: "))
-			data = bytes.TrimSpace(data)
-
-			if string(data) == "[DONE]" {
-				break
-			}
+	for round := 1; ; round++ {
+		// 通知客户端建立连接期间发生的重试与降级
+		for _, event := range events {
+			w.send(event.Event, event.Data)
+		}
+		result, err := handleSSEStream(ctx, w, up.provider, up.resp.Body)
+		up.resp.Body.Close()
+		result.model = up.model
+		usage = addUsage(usage, result.usage)
+		if firstToken.IsZero() {
+			firstToken = result.firstToken
+		}
+		if err != nil || len(result.toolCalls) == 0 {
+			return finish(result), err
+		}
 
-			fullResponse, err = processSSEData(c, data, fullResponse)
-			if err != nil {
-				return "", err
-			}
+		if round >= config.AppConfig.Tools.MaxRounds {
+			return finish(result), fmt.Errorf("tool call limit of %d rounds exceeded", config.AppConfig.Tools.MaxRounds)
+		}
+		if err := runTools(ctx, w, conversation, result); err != nil {
+			return finish(&streamResult{model: result.model}), err
+		}
+		// 带上工具结果继续请求
+		up, events, err = openUpstream(ctx, conversation, params, files)
+		if err != nil {
+			return finish(&streamResult{model: result.model}), err
 		}
 	}
+}
 
-	// 发送流式完成消息
-	sendSSEEvent(c, "done", "Stream finished")
-	return fullResponse, nil
+// addUsage 累加多轮请求的 token 用量
+func addUsage(total, usage *models.Usage) *models.Usage {
+	if usage == nil {
+		return total
+	}
+	if total == nil {
+		return &models.Usage{PromptTokens: usage.PromptTokens, CompletionTokens: usage.CompletionTokens, TotalTokens: usage.TotalTokens}
+	}
+	total.PromptTokens += usage.PromptTokens
+	total.CompletionTokens += usage.CompletionTokens
+	total.TotalTokens += usage.TotalTokens
+	return total
 }
 
-// processSSEData 处理单条 SSE 数据
-func processSSEData(c *gin.Context, data []byte, fullResponse string) (string, error) {
-	var sseResponse *models.SSEResponse
-	if err := json.Unmarshal(data, &sseResponse); err != nil {
-		sendSSEEvent(c, "error", fmt.Sprintf("Failed to unmarshal SSE data: %v", err))
-		return fullResponse, nil
+// handleSSEStream 处理上游流式数据，w 为空时只汇总不推送；出错时返回已收到的部分内容
+func handleSSEStream(ctx context.Context, w *sseWriter, provider providers.Provider, body io.Reader) (*streamResult, error) {
+	result := &streamResult{}
+
+	err := provider.DecodeStream(body, func(chunk *providers.StreamChunk) error {
+		// 客户端已断开，停止读取上游
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		processSSEData(w, chunk, result)
+		return nil
+	})
+	return result, err
+}
+
+// processSSEData 处理单条流式增量
+func processSSEData(w *sseWriter, chunk *providers.StreamChunk, result *streamResult) {
+	if chunk.Error != "" {
+		w.send("error", chunk.Error)
+		return
+	}
+	if chunk.FinishReason != "" {
+		result.finishReason = chunk.FinishReason
+	}
+	if chunk.Usage != nil {
+		result.usage = chunk.Usage
+	}
+	for _, delta := range chunk.ToolCalls {
+		result.addToolCallDelta(delta)
+	}
+	if chunk.Content == "" {
+		return
 	}
 
-	for _, choice := range sseResponse.Choices {
-		content := choice.Delta.Content
-		fullResponse += content
-		sendSSEEvent(c, "message", content)
+	if result.firstToken.IsZero() {
+		result.firstToken = time.Now()
 	}
-	return fullResponse, nil
+	result.content += chunk.Content
+	w.send("message", chunk.Content)
 }
 
-// sendSSEEvent 发送 SSE 消息到客户端
-func sendSSEEvent(c *gin.Context, event, data string) {
-	message, _ := json.Marshal(map[string]string{
-		"event": event,
-		"data":  data,
-	})
-	fmt.Fprintf(c.Writer, "%s\n\n", message)
-	c.Writer.Flush()
+// saveAnswer 保存生成结果并记录用量；streamErr 非空表示生成中断（客户端断开、上游出错或被停止），此时只保存已收到的部分回复
+func saveAnswer(userID int64, conversation *models.Conversation, result *streamResult, streamErr error) error {
+	aiMessage := models.Message{
+		Role:      "assistant",
+		Content:   result.content,
+		Model:     result.model,
+		Usage:     result.usage,
+		LatencyMs: result.latency.Milliseconds(),
+		TTFTMs:    result.ttft.Milliseconds(),
+	}
+	aiMessage.Cost, _ = billing.Cost(result.model, result.usage)
+	// 写锁已丢失时会话可能已被其他请求修改，部分回复也不再保存
+	if errors.Is(streamErr, ErrConversationLockLost) {
+		return streamErr
+	}
+	if streamErr != nil {
+		if result.content != "" {
+			log.Printf("conversation %d: stream interrupted, saving partial answer: %v", conversation.ID, streamErr)
+			if errors.Is(streamErr, ErrGenerationStopped) {
+				aiMessage.Stopped = true
+			} else {
+				aiMessage.Interrupted = true
+			}
+			if err := saveConversationWithAIResponse(conversation, aiMessage); err != nil {
+				log.Printf("conversation %d: failed to save partial answer: %v", conversation.ID, err)
+			} else {
+				recordUsage(userID, conversation.ID, conversation.Messages[len(conversation.Messages)-1])
+			}
+		}
+		return streamErr
+	}
+
+	if err := saveConversationWithAIResponse(conversation, aiMessage); err != nil {
+		return err
+	}
+	recordUsage(userID, conversation.ID, conversation.Messages[len(conversation.Messages)-1])
+	return nil
 }
 
-func saveConversationWithAIResponse(conversation *models.Conversation, fullResponse string) error {
-	// 构造 AI 回复消息
-	aiMessage := models.Message{Role: "assistant", Content: fullResponse, MessageID: int32(len(conversation.Messages))}
+// saveConversationWithAIResponse 追加 AI 回复（或工具结果）并保存会话
+func saveConversationWithAIResponse(conversation *models.Conversation, aiMessage models.Message) error {
+	aiMessage.MessageID = nextMessageID(conversation)
 	// 追加到会话记录
 	conversation.Messages = append(conversation.Messages, aiMessage)
 	// 保存对话记录到 Redis
-	return storage.SaveConversationToRedis(conversation)
+	return saveConversation(conversation)
 }
 
-// sendStreamEndMessage 发送流结束消息
-func sendStreamEndMessage(c *gin.Context, fullResponse string) {
-	endMessage, _ := json.Marshal(map[string]string{
-		"event": "done",
-		"data":  "Stream finished",
-	})
-	fmt.Fprintf(c.Writer, "%s\n\n", endMessage)
-	c.Writer.Flush()
+// nextMessageID 返回新消息的 ID。ID 在整棵消息树中唯一且只增不减，其他分支的消息仍保留原 ID
+func nextMessageID(conversation *models.Conversation) int32 {
+	var maxID int32
+	for _, messages := range [][]models.Message{conversation.Tree, conversation.Messages} {
+		for _, message := range messages {
+			maxID = max(maxID, message.MessageID)
+		}
+	}
+	return maxID + 1
+}
 
-	fmt.Fprintf(c.Writer, "{\"event\":\"full_response\",\"data\":\"%s\"}\n\n", fullResponse)
-	c.Writer.Flush()
+// sendStreamEndMessage 发送流结束消息与完整回复
+func sendStreamEndMessage(w *sseWriter, fullResponse string) {
+	w.send("done", "Stream finished")
+	w.send("full_response", fullResponse)
 }
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/EthanGuo-coder/llm-backend-api/constant"
+	"github.com/EthanGuo-coder/llm-backend-api/models"
+	"github.com/EthanGuo-coder/llm-backend-api/providers"
+)
+
+// 非流式接口在服务端汇总上游流，OpenAI 协议只有请求了 include_usage 才会在流末尾返回用量
+func TestHandleSSEStreamCollectsUsage(t *testing.T) {
+	provider := providers.NewOpenAIProvider(providers.Options{Name: "openai", BaseURL: "http://upstream.invalid", Auth: constant.AuthBearer})
+	req, err := provider.BuildRequest(context.Background(), &providers.ChatRequest{
+		Model:    "test-model",
+		ApiKey:   "test-key",
+		Messages: []models.Message{{Role: "user", Content: "hi"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sent struct {
+		StreamOptions struct {
+			IncludeUsage bool `json:"include_usage"`
+		} `json:"stream_options"`
+	}
+	if err := json.Unmarshal(body, &sent); err != nil {
+		t.Fatal(err)
+	}
+	if !sent.StreamOptions.IncludeUsage {
+		t.Errorf("request = %s, want stream_options.include_usage", body)
+	}
+
+	recorded, err := os.Open("../providers/testdata/openai_stream.sse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer recorded.Close()
+	// w 为空时只汇总不推送，与非流式接口一致
+	result, err := handleSSEStream(context.Background(), nil, provider, recorded)
+	if err != nil {
+		t.Fatalf("handleSSEStream() error = %v", err)
+	}
+	if result.content != "Hello, world" {
+		t.Errorf("content = %q, want %q", result.content, "Hello, world")
+	}
+	if result.finishReason != "tool_calls" {
+		t.Errorf("finish reason = %q, want tool_calls", result.finishReason)
+	}
+	want := models.Usage{PromptTokens: 12, CompletionTokens: 9, TotalTokens: 21}
+	if result.usage == nil || *result.usage != want {
+		t.Errorf("usage = %+v, want %+v", result.usage, want)
+	}
+}
@@ -0,0 +1,235 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/EthanGuo-coder/llm-backend-api/config"
+	"github.com/EthanGuo-coder/llm-backend-api/constant"
+	"github.com/EthanGuo-coder/llm-backend-api/models"
+	"github.com/EthanGuo-coder/llm-backend-api/providers"
+	"github.com/EthanGuo-coder/llm-backend-api/storage"
+	"github.com/EthanGuo-coder/llm-backend-api/tokenizer"
+)
+
+const (
+	// contextSummaryTTL 上下文摘要缓存时间
+	contextSummaryTTL = 24 * time.Hour
+	// maxSummaryChunks 单次摘要最多处理的分段数，更早的对话记录被舍弃，限制一次摘要的调用次数
+	maxSummaryChunks = 8
+	// minSummaryChunkTokens 每段对话记录的最小预算，避免上下文窗口过小时分段过碎
+	minSummaryChunkTokens = 512
+)
+
+// fitContext 返回发送给 model 的消息。history 超出上下文窗口时按配置的策略截断，
+// 会话中保存的历史不受影响。系统提示（含滚动摘要）与最近一轮始终保留
+func fitContext(ctx context.Context, conversation *models.Conversation, history []models.Message, model, apiKey string, params *models.GenerationParams, tools []models.ToolDefinition) []models.Message {
+	cfg := config.AppConfig.Context
+	estimator := tokenizer.ForModel(model)
+
+	reserve := cfg.ReserveTokens
+	if params != nil && params.MaxTokens != nil {
+		reserve = *params.MaxTokens
+	}
+	system, turns := splitTurns(history)
+	budget := tokenizer.ContextWindow(model) - reserve - estimator.CountTools(tools) - estimator.CountMessages(system)
+
+	maxTurns := len(turns)
+	if cfg.Strategy == constant.ContextLastN {
+		maxTurns = min(maxTurns, cfg.KeepTurns)
+	}
+	kept := keepRecentTurns(estimator, turns, budget, maxTurns)
+	if kept == len(turns) {
+		return history
+	}
+
+	dropped := turns[:len(turns)-kept]
+	if cfg.Strategy == constant.ContextSummarize {
+		// 为摘要预留空间后重新计算保留的轮次
+		kept = keepRecentTurns(estimator, turns, budget-cfg.SummaryMaxTokens, maxTurns)
+		dropped = turns[:len(turns)-kept]
+		summary, err := contextSummary(ctx, conversation, model, apiKey, flattenTurns(dropped))
+		if err != nil {
+			log.Printf("conversation %d: failed to summarize context, dropping oldest turns: %v", conversation.ID, err)
+		} else {
+			system = append(system, models.Message{Role: "system", Content: constant.SummaryPrefix + summary})
+		}
+	}
+	log.Printf("conversation %d: context for %s truncated, %d of %d turns kept", conversation.ID, model, kept, len(turns))
+
+	messages := append([]models.Message{}, system...)
+	return append(messages, flattenTurns(turns[len(turns)-kept:])...)
+}
+
+// splitTurns 拆出开头的系统提示，其余消息按轮次分组。
+// 一轮从 user 消息开始，包含其后的 assistant 与 tool 消息，保证工具调用与结果不被拆开
+func splitTurns(messages []models.Message) ([]models.Message, [][]models.Message) {
+	var system []models.Message
+	i := 0
+	for ; i < len(messages) && messages[i].Role == "system"; i++ {
+		system = append(system, messages[i])
+	}
+
+	var turns [][]models.Message
+	for ; i < len(messages); i++ {
+		if messages[i].Role == "user" || len(turns) == 0 {
+			turns = append(turns, nil)
+		}
+		turns[len(turns)-1] = append(turns[len(turns)-1], messages[i])
+	}
+	return system, turns
+}
+
+// keepRecentTurns 从最近一轮向前累加，返回预算内最多可保留的轮数，至少保留一轮
+func keepRecentTurns(estimator *tokenizer.Estimator, turns [][]models.Message, budget, maxTurns int) int {
+	kept, used := 0, 0
+	for i := len(turns) - 1; i >= 0 && kept < maxTurns; i-- {
+		used += estimator.CountMessages(turns[i])
+		if used > budget && kept > 0 {
+			break
+		}
+		kept++
+	}
+	return kept
+}
+
+// flattenTurns 将轮次展开为消息列表
+func flattenTurns(turns [][]models.Message) []models.Message {
+	var messages []models.Message
+	for _, turn := range turns {
+		messages = append(messages, turn...)
+	}
+	return messages
+}
+
+// contextSummary 获取被截断消息的摘要，按被摘要消息的首尾 ID 缓存；新生成摘要的用量计入会话所属用户
+func contextSummary(ctx context.Context, conversation *models.Conversation, model, apiKey string, messages []models.Message) (string, error) {
+	from, to := messages[0].MessageID, messages[len(messages)-1].MessageID
+	if summary, err := storage.GetContextSummary(conversation.ID, from, to); err == nil && summary != "" {
+		return summary, nil
+	}
+
+	summary, usage, err := summarizeMessages(ctx, model, apiKey, messages, config.AppConfig.Context.SummaryMaxTokens)
+	recordSummaryUsage(conversation, model, usage)
+	if err != nil {
+		return "", err
+	}
+	if err := storage.SetContextSummary(conversation.ID, from, to, summary, contextSummaryTTL); err != nil {
+		log.Printf("conversation %d: failed to cache context summary: %v", conversation.ID, err)
+	}
+	return summary, nil
+}
+
+// summarizeMessages 调用模型将一段对话压缩为摘要。对话记录超出摘要模型的上下文窗口时分段处理，
+// 每段连同前一段得到的摘要交给模型，最后一段的结果即为完整摘要；分段过多时舍弃最早的部分。
+// 返回各段调用累计的用量，失败时同样返回已产生的用量
+func summarizeMessages(ctx context.Context, model, apiKey string, messages []models.Message, maxTokens int) (string, *models.Usage, error) {
+	provider, err := providers.Resolve(model)
+	if err != nil {
+		return "", nil, err
+	}
+	estimator := tokenizer.ForModel(model)
+	// 每段的预算扣除提示、输出与随段携带的上一段摘要
+	budget := tokenizer.ContextWindow(model) - estimator.Count(constant.SummaryPrompt) - 2*maxTokens - 2*estimator.CountMessage(models.Message{})
+	chunks := transcriptChunks(estimator, messages, max(budget, minSummaryChunkTokens))
+	if len(chunks) == 0 {
+		return "", nil, errors.New("no text to summarize")
+	}
+	if len(chunks) > maxSummaryChunks {
+		log.Printf("summarizing with %s: transcript split into %d chunks, dropping the oldest %d", model, len(chunks), len(chunks)-maxSummaryChunks)
+		chunks = chunks[len(chunks)-maxSummaryChunks:]
+	}
+
+	var summary string
+	var usage *models.Usage
+	for _, chunk := range chunks {
+		if summary != "" {
+			chunk = constant.SummaryPrefix + summary + "\n\n" + chunk
+		}
+		result, err := summarizeTranscript(ctx, provider, model, apiKey, chunk, maxTokens)
+		if result != nil {
+			usage = addUsage(usage, result.usage)
+		}
+		if err != nil {
+			return "", usage, err
+		}
+		if result.content == "" {
+			return "", usage, fmt.Errorf("empty summary from %s", model)
+		}
+		summary = result.content
+	}
+	return summary, usage, nil
+}
+
+// summarizeTranscript 调用模型将一段纯文本对话记录压缩为摘要，返回汇总后的上游流；流中断时返回已收到的部分
+func summarizeTranscript(ctx context.Context, provider providers.Provider, model, apiKey, transcript string, maxTokens int) (*streamResult, error) {
+	chatReq := &providers.ChatRequest{
+		Model:  model,
+		ApiKey: apiKey,
+		Messages: []models.Message{
+			{Role: "system", Content: constant.SummaryPrompt},
+			{Role: "user", Content: transcript},
+		},
+		Params: &models.GenerationParams{MaxTokens: &maxTokens},
+	}
+
+	resp, err := sendAPIRequest(ctx, provider, chatReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := validateResponse(provider, resp); err != nil {
+		return nil, err
+	}
+	return handleSSEStream(ctx, nil, provider, resp.Body)
+}
+
+// transcriptChunks 将消息整理为纯文本对话记录，并按顺序切分为每段不超过 budget 个 token 的分段。
+// 单条消息超出 budget 时截断
+func transcriptChunks(estimator *tokenizer.Estimator, messages []models.Message, budget int) []string {
+	var chunks []string
+	var builder strings.Builder
+	used := 0
+	for _, message := range messages {
+		entry := formatTranscript([]models.Message{message})
+		if entry == "" {
+			continue
+		}
+		tokens := estimator.Count(entry)
+		if tokens > budget {
+			entry = estimator.Truncate(entry, budget-estimator.Count("\n\n")) + "\n\n"
+			tokens = estimator.Count(entry)
+		}
+		if used+tokens > budget && builder.Len() > 0 {
+			chunks = append(chunks, builder.String())
+			builder.Reset()
+			used = 0
+		}
+		builder.WriteString(entry)
+		used += tokens
+	}
+	if builder.Len() > 0 {
+		chunks = append(chunks, builder.String())
+	}
+	return chunks
+}
+
+// formatTranscript 将消息整理为纯文本对话记录
+func formatTranscript(messages []models.Message) string {
+	var builder strings.Builder
+	for _, message := range messages {
+		content := message.Content
+		for _, call := range message.ToolCalls {
+			content += fmt.Sprintf("\n[调用工具 %s: %s]", call.Name, call.Arguments)
+		}
+		if content == "" {
+			continue
+		}
+		fmt.Fprintf(&builder, "%s: %s\n\n", message.Role, content)
+	}
+	return builder.String()
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/EthanGuo-coder/llm-backend-api/models"
+	"github.com/EthanGuo-coder/llm-backend-api/tokenizer"
+)
+
+// roles 返回消息的角色序列，便于比较分组结果
+func roles(messages []models.Message) string {
+	names := make([]string, len(messages))
+	for i, message := range messages {
+		names[i] = message.Role
+	}
+	return strings.Join(names, ",")
+}
+
+func TestSplitTurns(t *testing.T) {
+	msg := func(role string) models.Message { return models.Message{Role: role, Content: role} }
+	tests := []struct {
+		name     string
+		messages []models.Message
+		system   string
+		turns    []string
+	}{
+		{"empty", nil, "", nil},
+		{"system only", []models.Message{msg("system")}, "system", nil},
+		{"simple turns",
+			[]models.Message{msg("system"), msg("user"), msg("assistant"), msg("user"), msg("assistant")},
+			"system", []string{"user,assistant", "user,assistant"}},
+		{"tool calls stay in their turn",
+			[]models.Message{msg("system"), msg("user"), msg("assistant"), msg("tool"), msg("assistant"), msg("user")},
+			"system", []string{"user,assistant,tool,assistant", "user"}},
+		{"leading system messages",
+			[]models.Message{msg("system"), msg("system"), msg("user")},
+			"system,system", []string{"user"}},
+		{"history starting without user",
+			[]models.Message{msg("system"), msg("assistant"), msg("user"), msg("assistant")},
+			"system", []string{"assistant", "user,assistant"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			system, turns := splitTurns(tt.messages)
+			if got := roles(system); got != tt.system {
+				t.Errorf("system = %q, want %q", got, tt.system)
+			}
+			var got []string
+			for _, turn := range turns {
+				got = append(got, roles(turn))
+			}
+			if !reflect.DeepEqual(got, tt.turns) {
+				t.Errorf("turns = %q, want %q", got, tt.turns)
+			}
+		})
+	}
+}
+
+func TestKeepRecentTurns(t *testing.T) {
+	estimator := tokenizer.ForModel("ollama/llama3")
+	// 每轮一条用户消息与一条回复，共 2*(4+4) = 16 个 token
+	turn := []models.Message{{Role: "user", Content: "hello world"}, {Role: "assistant", Content: "hello world"}}
+	turns := [][]models.Message{turn, turn, turn, turn}
+
+	tests := []struct {
+		name     string
+		budget   int
+		maxTurns int
+		want     int
+	}{
+		{"everything fits", 64, 4, 4},
+		{"budget cuts oldest", 40, 4, 2},
+		{"exact budget", 48, 4, 3},
+		{"max turns", 64, 1, 1},
+		{"at least one turn", 5, 4, 1},
+		{"negative budget", -10, 4, 1},
+		{"zero max turns", 64, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := keepRecentTurns(estimator, turns, tt.budget, tt.maxTurns); got != tt.want {
+				t.Errorf("keepRecentTurns(budget=%d, maxTurns=%d) = %d, want %d", tt.budget, tt.maxTurns, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTranscriptChunks(t *testing.T) {
+	estimator := tokenizer.ForModel("ollama/llama3")
+	long := strings.Repeat("word ", 200)
+	tests := []struct {
+		name     string
+		messages []models.Message
+		budget   int
+		chunks   int
+	}{
+		{"empty", nil, 100, 0},
+		{"skips empty messages", []models.Message{{Role: "assistant"}}, 100, 0},
+		{"one chunk", []models.Message{{Role: "user", Content: "hi"}, {Role: "assistant", Content: "hello"}}, 100, 1},
+		{"split by budget", []models.Message{
+			{Role: "user", Content: strings.Repeat("a ", 30)},
+			{Role: "assistant", Content: strings.Repeat("b ", 30)},
+			{Role: "user", Content: strings.Repeat("c ", 30)},
+		}, 50, 3},
+		{"oversized message truncated", []models.Message{{Role: "user", Content: long}, {Role: "assistant", Content: "ok"}}, 50, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks := transcriptChunks(estimator, tt.messages, tt.budget)
+			if len(chunks) != tt.chunks {
+				t.Fatalf("got %d chunks, want %d: %q", len(chunks), tt.chunks, chunks)
+			}
+			for i, chunk := range chunks {
+				if tokens := estimator.Count(chunk); tokens > tt.budget {
+					t.Errorf("chunk %d has %d tokens, budget %d", i, tokens, tt.budget)
+				}
+			}
+		})
+	}
+}
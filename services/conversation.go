@@ -10,17 +10,58 @@ import (
 	"github.com/EthanGuo-coder/llm-backend-api/utils"
 )
 
+// ErrConversationNotFound 会话不存在或不属于当前用户
+var ErrConversationNotFound = errors.New("conversation not found")
+
+// checkConversationOwner 会话不属于 userID 时返回 ErrConversationNotFound，不区分会话不存在与属于他人
+func checkConversationOwner(userID, conversationID int64) error {
+	owner, err := storage.GetConversationOwner(conversationID)
+	if err != nil {
+		return err
+	}
+	if owner == 0 || owner != userID {
+		return ErrConversationNotFound
+	}
+	return nil
+}
+
+// getUserConversation 读取属于 userID 的会话，不存在或属于他人时返回 ErrConversationNotFound
+func getUserConversation(userID, conversationID int64) (*models.Conversation, error) {
+	if err := checkConversationOwner(userID, conversationID); err != nil {
+		return nil, err
+	}
+	conversation, err := storage.GetConversationFromRedis(conversationID)
+	if err != nil {
+		return nil, errors.New("failed to fetch conversation from redis: " + err.Error())
+	}
+	if conversation == nil {
+		return nil, ErrConversationNotFound
+	}
+	conversation.UserID = userID
+	return conversation, nil
+}
+
 // CreateConversation 创建新的会话
-func CreateConversation(userID int64, title, model, apiKey string) (*models.CreateConversationResp, error) {
+func CreateConversation(userID int64, req *models.CreateConversationReq) (*models.CreateConversationResp, error) {
+	// 校验会话默认生成参数与关联的知识库
+	if err := validateParams(req.Model, req.Params); err != nil {
+		return nil, err
+	}
+	if err := checkKnowledgeBases(userID, req.KBIDs); err != nil {
+		return nil, err
+	}
+
 	// 生成唯一会话 ID
 	conversationID := utils.GenerateID()
 
 	// 构造会话对象
 	conversation := &models.Conversation{
 		ID:     conversationID,
-		Title:  title,
-		Model:  model,
-		ApiKey: apiKey, // 存储 api_key
+		Title:  req.Title,
+		Model:  req.Model,
+		ApiKey: req.ApiKey, // 存储 api_key
+		Params: req.Params,
+		KBIDs:  req.KBIDs,
 		Messages: []models.Message{
 			{Role: "system", Content: constant.SystemPrompt, MessageID: 0},
 		},
@@ -34,16 +75,18 @@ func CreateConversation(userID int64, title, model, apiKey string) (*models.Crea
 	}
 
 	// 初始化会话记录到 Redis
-	err = storage.SaveConversationToRedis(conversation)
+	err = storage.CreateConversationInRedis(conversation)
 	if err != nil {
 		return nil, errors.New("failed to save conversation to redis: " + err.Error())
 	}
 
 	conversationResp := &models.CreateConversationResp{
 		ID:          conversationID,
-		Title:       title,
-		Model:       model,
-		ApiKey:      apiKey,
+		Title:       req.Title,
+		Model:       req.Model,
+		ApiKey:      req.ApiKey,
+		Params:      req.Params,
+		KBIDs:       req.KBIDs,
 		CreatedTime: time.Now().Unix(),
 	}
 
@@ -61,29 +104,51 @@ func GetConversationHistory(conversationID int64) (*models.ConversationHistory,
 		return nil, errors.New("conversation not found")
 	}
 
+	return conversationHistory(conversation), nil
+}
+
+// conversationHistory 将会话转换为历史记录，只包含选中的分支，有多个分支的位置附带各分支的首条消息 ID
+func conversationHistory(conversation *models.Conversation) *models.ConversationHistory {
+	branches := childIDs(conversation.Tree)
+
 	// 过滤掉 role 为 "system" 的消息
 	filteredMessages := make([]models.Message, 0)
 	for _, message := range conversation.Messages {
 		if message.Role != "system" {
+			// 多模态片段返回文件地址与缩略图地址
+			if len(message.Parts) > 0 {
+				message.Parts = historyParts(message.Parts)
+			}
+			if siblings := branches[message.ParentID]; len(siblings) > 1 {
+				message.Branches = siblings
+			}
 			filteredMessages = append(filteredMessages, message)
 		}
 	}
 
 	// 转换为 ConversationHistory
-	history := &models.ConversationHistory{
-		ID:       conversation.ID,
-		Title:    conversation.Title,
-		Model:    conversation.Model,
-		Messages: filteredMessages, // 使用过滤后的消息
+	return &models.ConversationHistory{
+		ID:         conversation.ID,
+		Title:      conversation.Title,
+		Model:      conversation.Model,
+		Params:     conversation.Params,
+		KBIDs:      conversation.KBIDs,
+		ForkedFrom: conversation.ForkedFrom,
+		Messages:   filteredMessages, // 使用过滤后的消息
 	}
-
-	return history, nil
 }
 
 // DeleteUserConversation 删除指定的用户对话
 func DeleteUserConversation(userID int64, conversationID int64) error {
+	// 生成进行中时不能删除，否则生成结束时的保存会重新写入会话
+	lock, err := lockConversation(conversationID)
+	if err != nil {
+		return err
+	}
+	defer lock.unlock()
+
 	// 从数据库删除会话元信息
-	err := storage.DeleteConversationFromDB(userID, conversationID)
+	err = storage.DeleteConversationFromDB(userID, conversationID)
 	if err != nil {
 		return errors.New("failed to delete conversation from database: " + err.Error())
 	}
@@ -94,6 +159,11 @@ func DeleteUserConversation(userID int64, conversationID int64) error {
 		return errors.New("failed to delete conversation from redis: " + err.Error())
 	}
 
+	// 删除滚动摘要
+	if err := deleteMemory(conversationID); err != nil {
+		return errors.New("failed to delete conversation memory: " + err.Error())
+	}
+
 	return nil
 }
 
@@ -111,6 +181,7 @@ func GetUserConversations(userID int64) ([]models.ConversationSummary, error) {
 		summaries = append(summaries, models.ConversationSummary{
 			ID:          conversation.ID,
 			Title:       conversation.Title,
+			ForkedFrom:  conversation.ForkedFrom,
 			CreatedTime: conversation.CreatedTime,
 		})
 	}
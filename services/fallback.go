@@ -0,0 +1,130 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/EthanGuo-coder/llm-backend-api/models"
+	"github.com/EthanGuo-coder/llm-backend-api/providers"
+)
+
+// upstream 已成功建立的上游流式连接
+type upstream struct {
+	model    string
+	provider providers.Provider
+	resp     *http.Response
+	started  time.Time // 开始建立连接的时间，含重试与降级的耗时
+}
+
+// upstreamEvent 建立上游连接期间产生的事件，连接成功后按顺序推送给客户端
+type upstreamEvent struct {
+	Event string
+	Data  interface{}
+}
+
+// fallbackEvent 降级事件，通过 SSE fallback 事件通知客户端
+type fallbackEvent struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Reason string `json:"reason"`
+}
+
+// FallbackAttempt 降级链中一次未成功的尝试
+type FallbackAttempt struct {
+	Model  string `json:"model"`
+	Reason string `json:"reason"`
+}
+
+// FallbackError 降级链中的模型全部失败，Attempts 按尝试顺序记录每个模型及其失败原因，
+// Unwrap 返回最后一个模型的错误
+type FallbackError struct {
+	Attempts []FallbackAttempt
+	err      error
+}
+
+func (e *FallbackError) Error() string {
+	reasons := make([]string, len(e.Attempts))
+	for i, attempt := range e.Attempts {
+		reasons[i] = fmt.Sprintf("%s: %s", attempt.Model, attempt.Reason)
+	}
+	return "all models in the fallback chain failed (" + strings.Join(reasons, "; ") + ")"
+}
+
+func (e *FallbackError) Unwrap() error {
+	return e.err
+}
+
+// openUpstream 依次尝试会话模型及其降级链，返回首个成功建立的上游连接。
+// params 已按主模型校验，降级模型不支持这些参数时跳过该模型。files 为本次请求共用的文件缓存
+func openUpstream(ctx context.Context, conversation *models.Conversation, params *models.GenerationParams, files fileCache) (*upstream, []upstreamEvent, error) {
+	chain := providers.Chain(conversation.Model)
+	var events []upstreamEvent
+	var primary, lastModel string
+	var lastErr error
+	var attempts []FallbackAttempt
+	started := time.Now()
+
+	for i, model := range chain {
+		provider, err := providers.Resolve(model)
+		if err != nil {
+			return nil, nil, err
+		}
+		if i == 0 {
+			primary = provider.Name()
+		} else {
+			if err := provider.ValidateParams(params); err != nil {
+				log.Printf("conversation %d: skipping fallback model %s: %v", conversation.ID, model, err)
+				attempts = append(attempts, FallbackAttempt{Model: model, Reason: err.Error()})
+				continue
+			}
+			log.Printf("conversation %d: model %s failed, falling back to %s: %v", conversation.ID, lastModel, model, lastErr)
+			events = append(events, upstreamEvent{
+				Event: "fallback",
+				Data:  fallbackEvent{From: lastModel, To: model, Reason: lastErr.Error()},
+			})
+		}
+
+		// 会话密钥只属于主服务商，降级到其他服务商时使用其配置的密钥
+		apiKey := conversation.ApiKey
+		if provider.Name() != primary {
+			apiKey = ""
+		}
+		chatReq := buildRequestBody(ctx, conversation, model, apiKey, params, files)
+
+		resp, err := openWithRetry(ctx, conversation.ID, provider, chatReq, &events)
+		if err == nil {
+			return &upstream{model: model, provider: provider, resp: resp, started: started}, events, nil
+		}
+		if !isTransient(err) {
+			return nil, nil, err
+		}
+
+		lastModel, lastErr = model, err
+		attempts = append(attempts, FallbackAttempt{Model: model, Reason: err.Error()})
+	}
+	// 没有配置降级模型时原样返回主模型的错误
+	if len(chain) == 1 {
+		return nil, nil, lastErr
+	}
+	return nil, nil, &FallbackError{Attempts: attempts, err: lastErr}
+}
+
+// isTransient 限流、5xx 与连接错误属于临时错误，可以重试或切换到降级模型
+func isTransient(err error) bool {
+	// 客户端主动断开不属于上游故障
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	var apiErr *providers.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Temporary()
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
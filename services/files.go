@@ -0,0 +1,408 @@
+package services
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif" // 注册 GIF 解码器
+	"image/jpeg"
+	_ "image/png" // 注册 PNG 解码器
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/EthanGuo-coder/llm-backend-api/config"
+	"github.com/EthanGuo-coder/llm-backend-api/models"
+	"github.com/EthanGuo-coder/llm-backend-api/storage"
+)
+
+const (
+	// thumbnailSize 缩略图最长边像素
+	thumbnailSize = 256
+	// maxImagePixels 生成缩略图的图片像素上限，解码前按图片头校验，避免小文件声明超大尺寸耗尽内存
+	maxImagePixels = 50_000_000
+)
+
+var (
+	// ErrInvalidContent 消息片段格式错误或引用了无效的文件
+	ErrInvalidContent = errors.New("invalid message content")
+	// ErrFileNotFound 文件不存在或不属于当前用户
+	ErrFileNotFound = errors.New("file not found")
+	// ErrFileTooLarge 文件超过大小上限
+	ErrFileTooLarge = errors.New("file too large")
+)
+
+// rasterImageTypes 可作为图片片段发送给模型、可在浏览器中内联显示的位图格式。
+// SVG 等可携带脚本的格式不在其中，只能作为附件下载
+var rasterImageTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// IsRasterImage 判断 MIME 类型是否为允许内联显示的位图
+func IsRasterImage(mimeType string) bool {
+	return rasterImageTypes[mimeType]
+}
+
+// UploadFile 保存上传的文件，图片同时生成缩略图
+func UploadFile(userID int64, filename string, data []byte) (*models.UploadFileResp, error) {
+	mimeType := http.DetectContentType(data)
+	file, err := saveFile(userID, filename, mimeType, data)
+	if err != nil {
+		return nil, err
+	}
+	return uploadFileResp(file), nil
+}
+
+// GetFile 读取文件内容，thumbnail 为 true 时优先返回缩略图
+func GetFile(userID int64, fileID string, thumbnail bool) (*models.File, []byte, error) {
+	file, err := getUserFile(userID, fileID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key := file.ID
+	if thumbnail && file.HasThumbnail {
+		key = thumbnailKey(file.ID)
+	}
+	data, err := storage.GetBlob(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if key != file.ID {
+		file.MimeType = "image/jpeg"
+	}
+	return file, data, nil
+}
+
+// MaxFileSize 单个文件的大小上限（字节），0 表示不限
+func MaxFileSize() int64 {
+	return config.AppConfig.Files.MaxSize * 1024 * 1024
+}
+
+// CheckFileSize 文件超过大小上限时返回 ErrFileTooLarge
+func CheckFileSize(size int64) error {
+	if maxSize := MaxFileSize(); maxSize > 0 && size > maxSize {
+		return fmt.Errorf("%w: limit is %d MB", ErrFileTooLarge, config.AppConfig.Files.MaxSize)
+	}
+	return nil
+}
+
+// saveFile 保存文件内容与元信息
+func saveFile(userID int64, filename, mimeType string, data []byte) (*models.File, error) {
+	if err := CheckFileSize(int64(len(data))); err != nil {
+		return nil, err
+	}
+
+	file := &models.File{
+		ID:          newFileID(),
+		UserID:      userID,
+		Filename:    filename,
+		MimeType:    mimeType,
+		Size:        int64(len(data)),
+		CreatedTime: time.Now().Unix(),
+	}
+	if err := storage.PutBlob(file.ID, data); err != nil {
+		return nil, err
+	}
+	if IsRasterImage(mimeType) {
+		// 无法解码的格式（如 WebP）不生成缩略图，查看缩略图时返回原图
+		if thumbnail, err := makeThumbnail(data); err == nil {
+			if err := storage.PutBlob(thumbnailKey(file.ID), thumbnail); err == nil {
+				file.HasThumbnail = true
+			}
+		}
+	}
+	if err := storage.SaveFileToDB(file); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// getUserFile 获取属于该用户的文件元信息
+func getUserFile(userID int64, fileID string) (*models.File, error) {
+	file, err := storage.GetFileFromDB(fileID)
+	if err != nil {
+		return nil, err
+	}
+	if file == nil || file.UserID != userID {
+		return nil, fmt.Errorf("%w: %s", ErrFileNotFound, fileID)
+	}
+	return file, nil
+}
+
+// newFileID 生成不可猜测的文件 ID
+func newFileID() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// thumbnailKey 缩略图在 Blob 存储中的 key
+func thumbnailKey(fileID string) string {
+	return fileID + "_thumb"
+}
+
+// fileURL、thumbnailURL 文件与缩略图的下载地址
+func fileURL(fileID string) string {
+	return "/api/files/" + fileID
+}
+
+func thumbnailURL(fileID string) string {
+	return "/api/files/" + fileID + "/thumbnail"
+}
+
+// uploadFileResp 构造上传响应
+func uploadFileResp(file *models.File) *models.UploadFileResp {
+	resp := &models.UploadFileResp{File: *file, URL: fileURL(file.ID)}
+	if IsRasterImage(file.MimeType) {
+		resp.ThumbnailURL = thumbnailURL(file.ID)
+	}
+	return resp
+}
+
+// makeThumbnail 按最长边等比缩小图片并编码为 JPEG，像素数超过 maxImagePixels 的图片不生成缩略图
+func makeThumbnail(data []byte) ([]byte, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || int64(cfg.Width)*int64(cfg.Height) > maxImagePixels {
+		return nil, fmt.Errorf("image too large for a thumbnail: %dx%d", cfg.Width, cfg.Height)
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > thumbnailSize || height > thumbnailSize {
+		if width >= height {
+			width, height = thumbnailSize, height*thumbnailSize/width
+		} else {
+			width, height = width*thumbnailSize/height, thumbnailSize
+		}
+	}
+	width, height = max(width, 1), max(height, 1)
+
+	// 最近邻采样，缩略图无需更高质量
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			srcX := bounds.Min.X + x*bounds.Dx()/width
+			srcY := bounds.Min.Y + y*bounds.Dy()/height
+			dst.Set(x, y, src.At(srcX, srcY))
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 80}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// normalizeParts 校验用户消息片段：内联图片转存为文件，文件引用必须属于该用户。
+// 返回规范化后的片段与其中文本片段的拼接
+func normalizeParts(userID int64, message string, parts []models.ContentPart) ([]models.ContentPart, string, error) {
+	if message != "" {
+		parts = append([]models.ContentPart{{Type: models.PartText, Text: message}}, parts...)
+	}
+
+	normalized := make([]models.ContentPart, 0, len(parts))
+	var texts []string
+	for _, part := range parts {
+		switch part.Type {
+		case models.PartText:
+			texts = append(texts, part.Text)
+		case models.PartImageURL:
+			if part.ImageURL == nil {
+				return nil, "", fmt.Errorf("%w: image_url is required", ErrInvalidContent)
+			}
+			img := *part.ImageURL
+			img.ThumbnailURL = ""
+			if err := normalizeImage(userID, &img); err != nil {
+				return nil, "", err
+			}
+			part.ImageURL = &img
+		case models.PartFile:
+			if part.File == nil || part.File.FileID == "" {
+				return nil, "", fmt.Errorf("%w: file.file_id is required", ErrInvalidContent)
+			}
+			file, err := getUserFile(userID, part.File.FileID)
+			if err != nil {
+				return nil, "", err
+			}
+			part.File = &models.FileRef{FileID: file.ID, Filename: file.Filename}
+		default:
+			return nil, "", fmt.Errorf("%w: unsupported part type %q", ErrInvalidContent, part.Type)
+		}
+		normalized = append(normalized, part)
+	}
+	return normalized, strings.Join(texts, "\n"), nil
+}
+
+// normalizeImage 校验图片片段，data URL 转存为文件后只保留 file_id。
+// data URL 中声明的类型不可信，按内容识别，只接受位图
+func normalizeImage(userID int64, img *models.ImageURL) error {
+	switch {
+	case img.FileID != "":
+		file, err := getUserFile(userID, img.FileID)
+		if err != nil {
+			return err
+		}
+		if !IsRasterImage(file.MimeType) {
+			return fmt.Errorf("%w: file %s is not a png, jpeg, gif or webp image", ErrInvalidContent, file.ID)
+		}
+		img.URL = ""
+	case strings.HasPrefix(img.URL, "data:"):
+		meta, encoded, ok := strings.Cut(strings.TrimPrefix(img.URL, "data:"), ",")
+		mimeType := strings.TrimSuffix(meta, ";base64")
+		if !ok || mimeType == meta || !strings.HasPrefix(mimeType, "image/") {
+			return fmt.Errorf("%w: image data URL must be base64 encoded", ErrInvalidContent)
+		}
+		data, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return fmt.Errorf("%w: invalid base64 image", ErrInvalidContent)
+		}
+		mimeType = http.DetectContentType(data)
+		if !IsRasterImage(mimeType) {
+			return fmt.Errorf("%w: image must be png, jpeg, gif or webp", ErrInvalidContent)
+		}
+		file, err := saveFile(userID, "image", mimeType, data)
+		if err != nil {
+			return err
+		}
+		img.FileID, img.URL = file.ID, ""
+	case strings.HasPrefix(img.URL, "http://") || strings.HasPrefix(img.URL, "https://"):
+	default:
+		return fmt.Errorf("%w: image url must be http(s), a data URL or a file_id", ErrInvalidContent)
+	}
+	return nil
+}
+
+// fileCache 单次请求内已读取的文件。降级模型与工具调用的每一轮都重新构造请求体，
+// 共用缓存使每个文件在一次请求中只读取、编码一次
+type fileCache map[string]*cachedFile
+
+// cachedFile 已读取的文件元信息、内容与其 data URL
+type cachedFile struct {
+	file    *models.File
+	data    []byte
+	dataURL string
+}
+
+// read 返回文件及其 data URL，首次读取后缓存
+func (c fileCache) read(fileID string) (*cachedFile, error) {
+	if cached, ok := c[fileID]; ok {
+		return cached, nil
+	}
+	file, data, err := readFile(fileID)
+	if err != nil {
+		return nil, err
+	}
+	cached := &cachedFile{file: file, data: data}
+	if !isTextFile(file.MimeType) {
+		cached.dataURL = dataURL(file.MimeType, data)
+	}
+	c[fileID] = cached
+	return cached, nil
+}
+
+// resolveMessages 复制对话历史，把片段中引用的文件内容填充为 data URL，供服务商转换。
+// 文本类文件直接展开为文本片段；读取失败的文件会被跳过
+func resolveMessages(messages []models.Message, files fileCache) []models.Message {
+	resolved := make([]models.Message, len(messages))
+	copy(resolved, messages)
+
+	for i, message := range resolved {
+		if len(message.Parts) == 0 {
+			continue
+		}
+		parts := make([]models.ContentPart, 0, len(message.Parts))
+		for _, part := range message.Parts {
+			switch {
+			case part.Type == models.PartImageURL && part.ImageURL != nil && part.ImageURL.FileID != "":
+				cached, err := files.read(part.ImageURL.FileID)
+				if err != nil {
+					log.Printf("skipping image %s: %v", part.ImageURL.FileID, err)
+					continue
+				}
+				img := *part.ImageURL
+				img.URL = cached.dataURL
+				part.ImageURL = &img
+			case part.Type == models.PartFile && part.File != nil:
+				cached, err := files.read(part.File.FileID)
+				if err != nil {
+					log.Printf("skipping file %s: %v", part.File.FileID, err)
+					continue
+				}
+				if isTextFile(cached.file.MimeType) {
+					part = models.ContentPart{Type: models.PartText, Text: fmt.Sprintf("File: %s\n\n%s", cached.file.Filename, cached.data)}
+				} else {
+					ref := *part.File
+					ref.Data = cached.dataURL
+					part.File = &ref
+				}
+			}
+			parts = append(parts, part)
+		}
+		resolved[i].Parts = parts
+	}
+	return resolved
+}
+
+// readFile 按 ID 读取文件元信息与内容，不校验归属（片段写入会话时已校验）
+func readFile(fileID string) (*models.File, []byte, error) {
+	file, err := storage.GetFileFromDB(fileID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if file == nil {
+		return nil, nil, ErrFileNotFound
+	}
+	data, err := storage.GetBlob(file.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return file, data, nil
+}
+
+// historyParts 为历史记录中的片段填充下载地址与缩略图地址
+func historyParts(parts []models.ContentPart) []models.ContentPart {
+	converted := make([]models.ContentPart, len(parts))
+	for i, part := range parts {
+		if part.ImageURL != nil && part.ImageURL.FileID != "" {
+			img := *part.ImageURL
+			img.URL = fileURL(img.FileID)
+			img.ThumbnailURL = thumbnailURL(img.FileID)
+			part.ImageURL = &img
+		}
+		if part.File != nil {
+			ref := *part.File
+			ref.URL = fileURL(ref.FileID)
+			part.File = &ref
+		}
+		converted[i] = part
+	}
+	return converted
+}
+
+// dataURL 将文件内容编码为 data URL
+func dataURL(mimeType string, data []byte) string {
+	return "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(data)
+}
+
+// isTextFile 文本类文件可直接展开给模型
+func isTextFile(mimeType string) bool {
+	return strings.HasPrefix(mimeType, "text/") || strings.HasPrefix(mimeType, "application/json")
+}
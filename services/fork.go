@@ -0,0 +1,124 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/EthanGuo-coder/llm-backend-api/models"
+	"github.com/EthanGuo-coder/llm-backend-api/providers"
+	"github.com/EthanGuo-coder/llm-backend-api/storage"
+	"github.com/EthanGuo-coder/llm-backend-api/utils"
+)
+
+// ForkConversation 将 userID 的会话中从首条消息到 req.MessageID 的分支复制为新会话。
+// 新会话沿用源会话的模型、密钥、参数与知识库，请求中提供的字段覆盖对应设置；
+// 更换到其他服务商的模型时不沿用密钥。响应中不返回密钥
+func ForkConversation(userID, conversationID int64, req *models.ForkConversationReq) (*models.CreateConversationResp, error) {
+	source, err := getUserConversation(userID, conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	messageID := *req.MessageID
+	found := false
+	for _, message := range source.Tree {
+		if message.MessageID == messageID {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("%w: no message %d", ErrMessageNotFound, messageID)
+	}
+
+	conversation := &models.Conversation{
+		ID:          utils.GenerateID(),
+		Title:       source.Title + " (fork)",
+		Model:       source.Model,
+		ApiKey:      source.ApiKey,
+		Params:      source.Params,
+		KBIDs:       source.KBIDs,
+		Messages:    storage.MessagePath(source.Tree, messageID),
+		ForkedFrom:  &models.ForkSource{ConversationID: source.ID, MessageID: messageID},
+		CreatedTime: time.Now().Unix(),
+	}
+	if req.Title != "" {
+		conversation.Title = req.Title
+	}
+	if req.Model != "" {
+		conversation.Model = req.Model
+		same, err := sameProvider(source.Model, req.Model)
+		if err != nil {
+			return nil, err
+		}
+		if !same {
+			conversation.ApiKey = ""
+		}
+	}
+	if req.ApiKey != nil {
+		conversation.ApiKey = *req.ApiKey
+	}
+	if req.Params != nil {
+		conversation.Params = req.Params
+	}
+	if req.KBIDs != nil {
+		conversation.KBIDs = *req.KBIDs
+		if err := checkKnowledgeBases(userID, conversation.KBIDs); err != nil {
+			return nil, err
+		}
+	}
+	// 沿用的参数也要按（可能更换后的）模型重新校验
+	if err := validateParams(conversation.Model, conversation.Params); err != nil {
+		return nil, err
+	}
+
+	// 保存会话元信息到数据库
+	if err := storage.SaveConversationToDB(userID, conversation); err != nil {
+		return nil, errors.New("failed to save conversation to database: " + err.Error())
+	}
+	// 保存复制的消息到 Redis
+	if err := storage.CreateConversationInRedis(conversation); err != nil {
+		return nil, errors.New("failed to save conversation to redis: " + err.Error())
+	}
+	forkMemory(source, conversation)
+
+	return &models.CreateConversationResp{
+		ID:          conversation.ID,
+		Title:       conversation.Title,
+		Model:       conversation.Model,
+		Params:      conversation.Params,
+		KBIDs:       conversation.KBIDs,
+		ForkedFrom:  conversation.ForkedFrom,
+		CreatedTime: conversation.CreatedTime,
+	}, nil
+}
+
+// sameProvider 判断两个模型是否由同一服务商提供
+func sameProvider(model, other string) (bool, error) {
+	provider, err := providers.Resolve(model)
+	if err != nil {
+		return false, err
+	}
+	otherProvider, err := providers.Resolve(other)
+	if err != nil {
+		return false, err
+	}
+	return provider.Name() == otherProvider.Name(), nil
+}
+
+// forkMemory 源会话的滚动摘要覆盖的消息都已复制时，新会话沿用该摘要；失败只记录日志
+func forkMemory(source, conversation *models.Conversation) {
+	memory, err := loadMemory(source.ID)
+	if err != nil {
+		log.Printf("conversation %d: failed to load memory for fork: %v", source.ID, err)
+		return
+	}
+	if _, ok := memoryThrough(conversation, memory); !ok {
+		return
+	}
+	if err := saveMemory(conversation.ID, memory); err != nil {
+		log.Printf("conversation %d: failed to copy memory: %v", conversation.ID, err)
+	}
+}
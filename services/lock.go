@@ -0,0 +1,123 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/EthanGuo-coder/llm-backend-api/models"
+	"github.com/EthanGuo-coder/llm-backend-api/storage"
+)
+
+// conversationLockTTL 会话写锁的过期时间，持有期间每隔三分之一续期一次；实例崩溃时锁最迟在此之后释放。
+// 测试中可缩短
+var conversationLockTTL = 30 * time.Second
+
+var (
+	// ErrConversationBusy 会话正被另一个请求修改（如生成进行中）
+	ErrConversationBusy = errors.New("conversation is busy with another request")
+	// ErrConversationLockLost 持有的会话写锁已过期或被他人获取（如续期长时间失败），本次修改未保存
+	ErrConversationLockLost = errors.New("conversation lock lost, changes were not saved")
+)
+
+// conversationLock 持有中的会话写锁
+type conversationLock struct {
+	conversationID int64
+	token          string
+	ctx            context.Context // 锁丢失时以 ErrConversationLockLost 取消
+	cancel         context.CancelCauseFunc
+	done           chan struct{}
+}
+
+// lockConversation 获取会话写锁，持有期间后台续期，用完须调用 unlock 释放。
+// 会话由 Redis 中的整块 JSON 读改写，持锁保证同一会话的发送、重新生成、编辑与设置修改串行执行，
+// 已被占用时返回 ErrConversationBusy，不排队等待。续期发现锁已丢失时取消 bind 得到的 ctx，
+// 持锁期间的保存都以锁的令牌为条件，锁丢失后不会覆盖其他请求的修改
+func lockConversation(conversationID int64) (*conversationLock, error) {
+	token := newLockToken()
+	ok, err := storage.AcquireConversationLock(conversationID, token, conversationLockTTL)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrConversationBusy
+	}
+
+	lock := &conversationLock{conversationID: conversationID, token: token, done: make(chan struct{})}
+	lock.ctx, lock.cancel = context.WithCancelCause(context.Background())
+	go func() {
+		ticker := time.NewTicker(conversationLockTTL / 3)
+		defer ticker.Stop()
+		// 最近一次确认仍持有锁的时间；续期持续失败超过 TTL 时锁必然已过期
+		held := time.Now()
+		for {
+			select {
+			case <-lock.done:
+				return
+			case <-ticker.C:
+				refreshed, err := storage.RefreshConversationLock(conversationID, token, conversationLockTTL)
+				switch {
+				case err == nil && refreshed:
+					held = time.Now()
+				case err == nil:
+					log.Printf("conversation %d: conversation lock lost", conversationID)
+					lock.cancel(ErrConversationLockLost)
+					return
+				case time.Since(held) >= conversationLockTTL:
+					log.Printf("conversation %d: conversation lock not refreshed within %s, treating it as lost: %v", conversationID, conversationLockTTL, err)
+					lock.cancel(ErrConversationLockLost)
+					return
+				default:
+					log.Printf("conversation %d: %v", conversationID, err)
+				}
+			}
+		}
+	}()
+	return lock, nil
+}
+
+// unlock 停止续期并释放写锁
+func (l *conversationLock) unlock() {
+	close(l.done)
+	l.cancel(nil)
+	if err := storage.ReleaseConversationLock(l.conversationID, l.token); err != nil {
+		log.Printf("conversation %d: %v", l.conversationID, err)
+	}
+}
+
+// bind 返回在锁丢失时以 ErrConversationLockLost 取消的 ctx，用于中止持锁期间的生成；结束后须调用返回的函数
+func (l *conversationLock) bind(ctx context.Context) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	stop := context.AfterFunc(l.ctx, func() { cancel(context.Cause(l.ctx)) })
+	return ctx, func() {
+		stop()
+		cancel(nil)
+	}
+}
+
+// attach 使 conversation 之后的保存以本锁的令牌为条件
+func (l *conversationLock) attach(conversation *models.Conversation) {
+	conversation.LockToken = l.token
+}
+
+// saveConversation 保存持锁修改的会话，写锁已不由 conversation.LockToken 持有时不写入并返回 ErrConversationLockLost
+func saveConversation(conversation *models.Conversation) error {
+	saved, err := storage.SaveConversationToRedis(conversation)
+	if err != nil {
+		return err
+	}
+	if !saved {
+		return ErrConversationLockLost
+	}
+	return nil
+}
+
+// newLockToken 生成锁持有者的随机令牌
+func newLockToken() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
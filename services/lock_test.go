@@ -0,0 +1,155 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"github.com/EthanGuo-coder/llm-backend-api/config"
+	"github.com/EthanGuo-coder/llm-backend-api/models"
+	"github.com/EthanGuo-coder/llm-backend-api/storage"
+)
+
+// useMiniredis 以测试用的内存 Redis 初始化存储，并缩短会话写锁的过期时间
+func useMiniredis(t *testing.T) *miniredis.Miniredis {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	previous, ttl := config.AppConfig, conversationLockTTL
+	config.AppConfig = &models.Config{}
+	config.AppConfig.Redis.Address = mr.Addr()
+	conversationLockTTL = 300 * time.Millisecond
+	t.Cleanup(func() { config.AppConfig, conversationLockTTL = previous, ttl })
+	if err := storage.InitializeRedis(); err != nil {
+		t.Fatalf("initialize redis: %v", err)
+	}
+	return mr
+}
+
+// waitCause 等待 ctx 被取消并返回取消原因，超时返回 nil
+func waitCause(ctx context.Context, timeout time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	case <-time.After(timeout):
+		return nil
+	}
+}
+
+func TestLockConversationBusy(t *testing.T) {
+	useMiniredis(t)
+	lock, err := lockConversation(1)
+	if err != nil {
+		t.Fatalf("lock: %v", err)
+	}
+	if _, err := lockConversation(1); !errors.Is(err, ErrConversationBusy) {
+		t.Errorf("second lock error = %v, want %v", err, ErrConversationBusy)
+	}
+	// 其他会话不受影响
+	other, err := lockConversation(2)
+	if err != nil {
+		t.Fatalf("lock other conversation: %v", err)
+	}
+	other.unlock()
+
+	lock.unlock()
+	again, err := lockConversation(1)
+	if err != nil {
+		t.Fatalf("lock after unlock: %v", err)
+	}
+	again.unlock()
+}
+
+func TestLockConversationLost(t *testing.T) {
+	tests := []struct {
+		name string
+		lose func(mr *miniredis.Miniredis)
+	}{
+		// 锁过期后被其他请求获取，续期时发现令牌不符
+		{"taken over", func(mr *miniredis.Miniredis) {
+			mr.Set(storage.GenerateRedisKeyConversationLock(1), "other")
+		}},
+		// Redis 不可用，续期持续失败超过 TTL
+		{"refresh keeps failing", func(mr *miniredis.Miniredis) {
+			mr.Close()
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mr := useMiniredis(t)
+			lock, err := lockConversation(1)
+			if err != nil {
+				t.Fatalf("lock: %v", err)
+			}
+			defer lock.unlock()
+			ctx, unbind := lock.bind(context.Background())
+			defer unbind()
+
+			// 正常续期时生成不被取消
+			if cause := waitCause(ctx, 2*conversationLockTTL); cause != nil {
+				t.Fatalf("generation cancelled while the lock is held: %v", cause)
+			}
+
+			tt.lose(mr)
+			if cause := waitCause(ctx, 3*conversationLockTTL); !errors.Is(cause, ErrConversationLockLost) {
+				t.Errorf("cause = %v, want %v", cause, ErrConversationLockLost)
+			}
+			if err := stopCause(ctx, ctx.Err()); !errors.Is(err, ErrConversationLockLost) {
+				t.Errorf("stopCause = %v, want %v", err, ErrConversationLockLost)
+			}
+		})
+	}
+}
+
+func TestSaveConversationAfterLockLost(t *testing.T) {
+	mr := useMiniredis(t)
+	conversation := &models.Conversation{ID: 1, Title: "original", Messages: []models.Message{{Role: "system"}}}
+	if err := storage.CreateConversationInRedis(conversation); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	lock, err := lockConversation(1)
+	if err != nil {
+		t.Fatalf("lock: %v", err)
+	}
+	defer lock.unlock()
+	lock.attach(conversation)
+
+	conversation.Title = "held"
+	if err := saveConversation(conversation); err != nil {
+		t.Fatalf("save while holding the lock: %v", err)
+	}
+
+	// 锁过期后被其他请求获取，旧令牌的保存被拒绝
+	mr.Set(storage.GenerateRedisKeyConversationLock(1), "other")
+	conversation.Title = "stale"
+	if err := saveConversation(conversation); !errors.Is(err, ErrConversationLockLost) {
+		t.Errorf("save error = %v, want %v", err, ErrConversationLockLost)
+	}
+	stored, err := storage.GetConversationFromRedis(1)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if stored.Title != "held" {
+		t.Errorf("stored title = %q, want %q", stored.Title, "held")
+	}
+}
+
+func TestConversationLockUnbind(t *testing.T) {
+	lock := &conversationLock{}
+	lock.ctx, lock.cancel = context.WithCancelCause(context.Background())
+	parent, cancelParent := context.WithCancel(context.Background())
+	defer cancelParent()
+
+	ctx, unbind := lock.bind(parent)
+	unbind()
+	// 生成结束后锁丢失不再影响已解绑的 ctx 的取消原因
+	lock.cancel(ErrConversationLockLost)
+	if cause := context.Cause(ctx); errors.Is(cause, ErrConversationLockLost) {
+		t.Errorf("cause = %v after unbind", cause)
+	}
+	if parent.Err() != nil {
+		t.Errorf("parent cancelled: %v", parent.Err())
+	}
+}
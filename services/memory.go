@@ -0,0 +1,223 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/EthanGuo-coder/llm-backend-api/config"
+	"github.com/EthanGuo-coder/llm-backend-api/constant"
+	"github.com/EthanGuo-coder/llm-backend-api/models"
+	"github.com/EthanGuo-coder/llm-backend-api/providers"
+	"github.com/EthanGuo-coder/llm-backend-api/storage"
+	"github.com/EthanGuo-coder/llm-backend-api/tokenizer"
+)
+
+// memoryUpdateTimeout 单次滚动摘要更新的超时，同时作为更新锁的过期时间
+const memoryUpdateTimeout = 2 * time.Minute
+
+var (
+	// ErrMemoryBusy 滚动摘要正在更新
+	ErrMemoryBusy = errors.New("conversation memory is being updated, try again later")
+	// ErrNothingToSummarize 除保留的最近轮次外没有可摘要的消息
+	ErrNothingToSummarize = errors.New("conversation is too short to summarize")
+)
+
+// GetConversationMemory 获取用户会话的滚动摘要，尚未生成时返回 nil
+func GetConversationMemory(userID, conversationID int64) (*models.ConversationMemory, error) {
+	if _, err := getUserConversation(userID, conversationID); err != nil {
+		return nil, err
+	}
+	return loadMemory(conversationID)
+}
+
+// RegenerateConversationMemory 丢弃现有摘要，对除最近 keep_turns 轮以外的全部历史重新生成
+func RegenerateConversationMemory(ctx context.Context, userID, conversationID int64) (*models.ConversationMemory, error) {
+	conversation, err := getUserConversation(userID, conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := storage.AcquireMemoryLock(conversationID, memoryUpdateTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrMemoryBusy
+	}
+	defer storage.ReleaseMemoryLock(conversationID)
+
+	ctx, cancel := context.WithTimeout(ctx, memoryUpdateTimeout)
+	defer cancel()
+	return updateMemory(ctx, conversation, nil, true)
+}
+
+// scheduleMemoryUpdate 回复保存后在后台检查是否需要更新滚动摘要，不阻塞本次响应
+func scheduleMemoryUpdate(conversation *models.Conversation) {
+	if !config.AppConfig.Memory.Enabled {
+		return
+	}
+	go func() {
+		ok, err := storage.AcquireMemoryLock(conversation.ID, memoryUpdateTimeout)
+		if err != nil || !ok {
+			return // 已有更新在进行，下次回复后再检查
+		}
+		defer storage.ReleaseMemoryLock(conversation.ID)
+
+		previous, err := loadMemory(conversation.ID)
+		if err != nil {
+			log.Printf("conversation %d: failed to load memory: %v", conversation.ID, err)
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), memoryUpdateTimeout)
+		defer cancel()
+		if _, err := updateMemory(ctx, conversation, previous, false); err != nil {
+			log.Printf("conversation %d: failed to update memory: %v", conversation.ID, err)
+		}
+	}()
+}
+
+// updateMemory 将 previous 之后、最近 keep_turns 轮之前的轮次并入摘要。
+// force 为 false 时仅在未摘要的历史超过阈值时更新，未更新时返回 previous
+func updateMemory(ctx context.Context, conversation *models.Conversation, previous *models.ConversationMemory, force bool) (*models.ConversationMemory, error) {
+	cfg := config.AppConfig.Memory
+
+	// 摘要覆盖的消息不在选中分支上（切换了分支）时，重新摘要当前分支
+	through, ok := memoryThrough(conversation, previous)
+	if !ok {
+		previous = nil
+	}
+	var pending [][]models.Message
+	_, turns := splitTurns(conversation.Messages)
+	for _, turn := range turns {
+		if previous == nil || turn[0].MessageID > through {
+			pending = append(pending, turn)
+		}
+	}
+	if !force && tokenizer.ForModel(conversation.Model).CountMessages(flattenTurns(pending)) < cfg.ThresholdTokens {
+		return previous, nil
+	}
+	if len(pending) <= cfg.KeepTurns {
+		if force {
+			return nil, ErrNothingToSummarize
+		}
+		return previous, nil
+	}
+
+	summarized := flattenTurns(pending[:len(pending)-cfg.KeepTurns])
+	// 已有摘要作为对话记录的开头一并交给模型，生成新的完整摘要
+	transcript := summarized
+	if previous != nil {
+		transcript = append([]models.Message{{Role: "system", Content: constant.SummaryPrefix + previous.Summary}}, summarized...)
+	}
+
+	model, apiKey := memoryModel(conversation)
+	summary, usage, err := summarizeMessages(ctx, model, apiKey, transcript, cfg.MaxTokens)
+	recordSummaryUsage(conversation, model, usage)
+	if err != nil {
+		return nil, err
+	}
+	memory := &models.ConversationMemory{
+		Summary:          summary,
+		ThroughMessageID: summarized[len(summarized)-1].MessageID,
+		Model:            model,
+		UpdatedTime:      time.Now().Unix(),
+	}
+	if err := saveMemory(conversation.ID, memory); err != nil {
+		return nil, err
+	}
+	log.Printf("conversation %d: memory updated through message %d", conversation.ID, memory.ThroughMessageID)
+	return memory, nil
+}
+
+// memoryModel 返回生成摘要的模型与密钥。会话密钥只属于会话模型的服务商，其他服务商使用其配置的密钥
+func memoryModel(conversation *models.Conversation) (string, string) {
+	model := config.AppConfig.Memory.Model
+	if model == "" {
+		return conversation.Model, conversation.ApiKey
+	}
+	summaryProvider, err := providers.Resolve(model)
+	if err != nil {
+		return model, ""
+	}
+	primary, err := providers.Resolve(conversation.Model)
+	if err != nil || primary.Name() != summaryProvider.Name() {
+		return model, ""
+	}
+	return model, conversation.ApiKey
+}
+
+// applyMemory 返回发送给模型的历史：系统提示之后注入滚动摘要，并去掉摘要已覆盖的消息。
+// 摘要属于其他分支时发送完整的选中分支
+func applyMemory(conversation *models.Conversation) []models.Message {
+	memory, err := loadMemory(conversation.ID)
+	if err != nil {
+		log.Printf("conversation %d: failed to load memory, sending full history: %v", conversation.ID, err)
+		return conversation.Messages
+	}
+	through, ok := memoryThrough(conversation, memory)
+	if !ok {
+		return conversation.Messages
+	}
+
+	system, turns := splitTurns(conversation.Messages)
+	messages := append([]models.Message{}, system...)
+	messages = append(messages, models.Message{Role: "system", Content: constant.SummaryPrefix + memory.Summary})
+	for _, message := range flattenTurns(turns) {
+		if message.MessageID > through {
+			messages = append(messages, message)
+		}
+	}
+	return messages
+}
+
+// memoryThrough 返回摘要覆盖的最后一条消息 ID。摘要不存在，或该消息不在选中分支上时返回 false。
+// 消息 ID 随创建递增，选中分支上在它之后的消息 ID 都更大
+func memoryThrough(conversation *models.Conversation, memory *models.ConversationMemory) (int32, bool) {
+	if memory == nil {
+		return 0, false
+	}
+	for _, message := range conversation.Messages {
+		if message.MessageID == memory.ThroughMessageID {
+			return memory.ThroughMessageID, true
+		}
+	}
+	return 0, false
+}
+
+// loadMemory 读取滚动摘要，Redis 未命中时从 SQLite 读取并回填
+func loadMemory(conversationID int64) (*models.ConversationMemory, error) {
+	memory, err := storage.GetMemoryFromRedis(conversationID)
+	if err != nil || memory != nil {
+		return memory, err
+	}
+	memory, err = storage.GetMemoryFromDB(conversationID)
+	if err != nil || memory == nil {
+		return memory, err
+	}
+	if err := storage.SaveMemoryToRedis(conversationID, memory); err != nil {
+		log.Printf("conversation %d: failed to cache memory: %v", conversationID, err)
+	}
+	return memory, nil
+}
+
+// saveMemory 保存滚动摘要到 SQLite 并刷新 Redis 缓存
+func saveMemory(conversationID int64, memory *models.ConversationMemory) error {
+	if err := storage.SaveMemoryToDB(conversationID, memory); err != nil {
+		return err
+	}
+	if err := storage.SaveMemoryToRedis(conversationID, memory); err != nil {
+		return fmt.Errorf("failed to cache memory: %v", err)
+	}
+	return nil
+}
+
+// deleteMemory 删除会话的滚动摘要
+func deleteMemory(conversationID int64) error {
+	if err := storage.DeleteMemoryFromDB(conversationID); err != nil {
+		return err
+	}
+	return storage.DeleteMemoryFromRedis(conversationID)
+}
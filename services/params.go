@@ -0,0 +1,79 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/EthanGuo-coder/llm-backend-api/models"
+	"github.com/EthanGuo-coder/llm-backend-api/providers"
+)
+
+// mergeParams 逐字段合并生成参数，override 中已设置的字段覆盖会话默认值
+func mergeParams(defaults, override *models.GenerationParams) *models.GenerationParams {
+	if override == nil {
+		return defaults
+	}
+	if defaults == nil {
+		return override
+	}
+
+	merged := *defaults
+	if override.Temperature != nil {
+		merged.Temperature = override.Temperature
+	}
+	if override.TopP != nil {
+		merged.TopP = override.TopP
+	}
+	if override.MaxTokens != nil {
+		merged.MaxTokens = override.MaxTokens
+	}
+	if override.Stop != nil {
+		merged.Stop = override.Stop
+	}
+	if override.Seed != nil {
+		merged.Seed = override.Seed
+	}
+	if override.PresencePenalty != nil {
+		merged.PresencePenalty = override.PresencePenalty
+	}
+	if override.FrequencyPenalty != nil {
+		merged.FrequencyPenalty = override.FrequencyPenalty
+	}
+	return &merged
+}
+
+// validateParams 按模型所属服务商校验生成参数，未设置参数时不校验
+func validateParams(model string, params *models.GenerationParams) error {
+	if params == nil {
+		return nil
+	}
+	provider, err := providers.Resolve(model)
+	if err != nil {
+		return err
+	}
+	return provider.ValidateParams(params)
+}
+
+// UpdateConversationParams 更新 userID 的会话默认生成参数，params 为空时清除；会话不存在或属于他人时返回 ErrConversationNotFound
+func UpdateConversationParams(userID, conversationID int64, params *models.GenerationParams) (*models.GenerationParams, error) {
+	// 与发送消息等请求互斥，避免整块读改写互相覆盖
+	lock, err := lockConversation(conversationID)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.unlock()
+
+	conversation, err := getUserConversation(userID, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	lock.attach(conversation)
+	if err := validateParams(conversation.Model, params); err != nil {
+		return nil, err
+	}
+
+	conversation.Params = params
+	if err := saveConversation(conversation); err != nil {
+		return nil, fmt.Errorf("failed to save conversation to redis: %w", err)
+	}
+	return conversation.Params, nil
+}
@@ -0,0 +1,184 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/EthanGuo-coder/llm-backend-api/config"
+	"github.com/EthanGuo-coder/llm-backend-api/models"
+	"github.com/EthanGuo-coder/llm-backend-api/storage"
+)
+
+// ErrUserNotFound 用户不存在
+var ErrUserNotFound = errors.New("user not found")
+
+// ErrInvalidQuota 配额取值不合法
+var ErrInvalidQuota = errors.New("invalid quota")
+
+// quotaPeriod 一个配额周期
+type quotaPeriod struct {
+	name    string // daily | monthly
+	id      string // Redis 计数键中的周期标识
+	limits  models.QuotaLimits
+	resetAt time.Time
+}
+
+// CheckQuota 检查用户配额并计入本次请求，超出任一周期的限额时返回超额详情。
+// 放行时返回的 refund 用于请求被拒绝（未开始生成）时退回计入的请求数；配额未启用时直接放行
+func CheckQuota(userID int64) (exceeded *models.QuotaExceeded, refund func(), err error) {
+	refund = func() {}
+	if !config.AppConfig.Quota.Enabled {
+		return nil, refund, nil
+	}
+	quota, _, err := effectiveQuota(userID)
+	if err != nil {
+		return nil, refund, err
+	}
+	periods := quotaPeriods(quota, time.Now())
+	windows := quotaWindows(periods)
+	rejection, err := storage.AdmitQuotaRequest(userID, windows)
+	if err != nil {
+		return nil, refund, err
+	}
+	if rejection == nil {
+		// 退回计入时所在的周期，请求跨过周期边界时也不会减到新周期上
+		refund = func() {
+			if err := storage.RefundQuotaRequest(userID, windows); err != nil {
+				log.Printf("user %d: %v", userID, err)
+			}
+		}
+		return nil, refund, nil
+	}
+
+	period := periods[rejection.Window]
+	exceeded = &models.QuotaExceeded{
+		Code:    "quota_exceeded",
+		Period:  period.name,
+		Used:    float64(rejection.Used),
+		ResetAt: period.resetAt.Unix(),
+	}
+	switch rejection.Field {
+	case "tokens":
+		exceeded.Metric, exceeded.Limit = "tokens", float64(period.limits.Tokens)
+	case "requests":
+		exceeded.Metric, exceeded.Limit = "requests", float64(period.limits.Requests)
+	case "cost":
+		exceeded.Metric, exceeded.Limit = "cost_usd", period.limits.Cost
+		exceeded.Used = fromMicros(rejection.Used)
+	}
+	exceeded.Error = fmt.Sprintf("%s %s quota exceeded", period.name, exceeded.Metric)
+	return exceeded, refund, nil
+}
+
+// addQuotaUsage 将回复或摘要调用的 token 与费用计入用户配额；失败只记录日志
+func addQuotaUsage(userID int64, tokens int, cost float64) {
+	if !config.AppConfig.Quota.Enabled {
+		return
+	}
+	periods := quotaPeriods(models.UserQuota{}, time.Now())
+	if err := storage.AddQuotaUsage(userID, quotaWindows(periods), int64(tokens), toMicros(cost)); err != nil {
+		log.Printf("user %d: %v", userID, err)
+	}
+}
+
+// GetQuotaStatus 获取用户生效的配额、各周期已用额度与重置时间
+func GetQuotaStatus(userID int64) (*models.QuotaStatus, error) {
+	quota, override, err := effectiveQuota(userID)
+	if err != nil {
+		return nil, err
+	}
+	periods := quotaPeriods(quota, time.Now())
+
+	status := &models.QuotaStatus{UserID: userID, Override: override}
+	for i, target := range []*models.QuotaPeriodStatus{&status.Daily, &status.Monthly} {
+		tokens, requests, costMicros, err := storage.GetQuotaUsage(userID, periods[i].id)
+		if err != nil {
+			return nil, err
+		}
+		*target = models.QuotaPeriodStatus{
+			Limits:  periods[i].limits,
+			Used:    models.QuotaUsage{Tokens: tokens, Requests: requests, Cost: fromMicros(costMicros)},
+			ResetAt: periods[i].resetAt.Unix(),
+		}
+	}
+	return status, nil
+}
+
+// SetUserQuota 为用户单独设置配额，覆盖默认配额
+func SetUserQuota(userID int64, quota *models.UserQuota) (*models.QuotaStatus, error) {
+	for _, limits := range []models.QuotaLimits{quota.Daily, quota.Monthly} {
+		if limits.Tokens < 0 || limits.Requests < 0 || limits.Cost < 0 {
+			return nil, fmt.Errorf("%w: limits must not be negative", ErrInvalidQuota)
+		}
+	}
+	exists, err := storage.UserExists(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrUserNotFound
+	}
+	if err := storage.SaveUserQuotaToDB(userID, quota); err != nil {
+		return nil, err
+	}
+	return GetQuotaStatus(userID)
+}
+
+// DeleteUserQuota 删除用户单独设置的配额，恢复为默认配额
+func DeleteUserQuota(userID int64) (*models.QuotaStatus, error) {
+	if err := storage.DeleteUserQuotaFromDB(userID); err != nil {
+		return nil, err
+	}
+	return GetQuotaStatus(userID)
+}
+
+// effectiveQuota 返回用户生效的配额，管理员单独设置的配额优先于默认配额
+func effectiveQuota(userID int64) (models.UserQuota, bool, error) {
+	override, err := storage.GetUserQuotaFromDB(userID)
+	if err != nil {
+		return models.UserQuota{}, false, err
+	}
+	if override != nil {
+		return *override, true, nil
+	}
+	return config.AppConfig.Quota.UserQuota, false, nil
+}
+
+// quotaPeriods 返回 now 所在的 UTC 自然日与自然月
+func quotaPeriods(quota models.UserQuota, now time.Time) []quotaPeriod {
+	now = now.UTC()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return []quotaPeriod{
+		{name: "daily", id: day.Format("d20060102"), limits: quota.Daily, resetAt: day.AddDate(0, 0, 1)},
+		{name: "monthly", id: month.Format("m200601"), limits: quota.Monthly, resetAt: month.AddDate(0, 1, 0)},
+	}
+}
+
+// quotaWindows 转换为 Redis 计数窗口，计数在周期结束后再保留一天
+func quotaWindows(periods []quotaPeriod) []storage.QuotaWindow {
+	windows := make([]storage.QuotaWindow, 0, len(periods))
+	for _, period := range periods {
+		windows = append(windows, storage.QuotaWindow{
+			Period:     period.id,
+			Tokens:     period.limits.Tokens,
+			Requests:   period.limits.Requests,
+			CostMicros: toMicros(period.limits.Cost),
+			TTL:        time.Until(period.resetAt) + 24*time.Hour,
+		})
+	}
+	return windows
+}
+
+// toMicros 美元转换为百万分之一美元
+func toMicros(usd float64) int64 {
+	return int64(math.Round(usd * 1e6))
+}
+
+// fromMicros 百万分之一美元转换为美元
+func fromMicros(micros int64) float64 {
+	return float64(micros) / 1e6
+}
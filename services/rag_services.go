@@ -106,13 +106,13 @@ func (s *RAGService) UploadDocument(uid, kbID, docName string, fileContent []byt
 	}, nil
 }
 
-// RetrieveInfo 从知识库检索信息
-func (s *RAGService) RetrieveInfo(kbID, query string, topK int) (*models.RetrieveResponse, error) {
+// RetrieveInfo 从知识库检索信息，ctx 取消（如客户端断开或生成被停止）时中止检索
+func (s *RAGService) RetrieveInfo(ctx context.Context, kbID, query string, topK int) (*models.RetrieveResponse, error) {
 	if topK <= 0 {
 		topK = DefaultTopK
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
 	resp, err := s.client.RetrieveInfo(ctx, kbID, query, topK)
@@ -214,9 +214,9 @@ func (s *RAGService) DeleteDocument(uid, kbID, docID string) (*models.DeleteDocu
 }
 
 // GenerateRagPrompt 生成基于知识库的对话提示
-func (s *RAGService) GenerateRagPrompt(kbID, query string, topK int) (string, error) {
+func (s *RAGService) GenerateRagPrompt(ctx context.Context, kbID, query string, topK int) (string, error) {
 	// 从知识库中检索相关信息
-	retrieveResp, err := s.RetrieveInfo(kbID, query, topK)
+	retrieveResp, err := s.RetrieveInfo(ctx, kbID, query, topK)
 	if err != nil {
 		return "", fmt.Errorf("从知识库检索信息失败: %w", err)
 	}
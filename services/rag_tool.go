@@ -0,0 +1,185 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/EthanGuo-coder/llm-backend-api/models"
+	"github.com/EthanGuo-coder/llm-backend-api/storage"
+	"github.com/EthanGuo-coder/llm-backend-api/tools"
+)
+
+const (
+	// KnowledgeBaseToolName 知识库检索工具名
+	KnowledgeBaseToolName = "search_knowledge_base"
+	// maxToolTopK 单次工具检索返回的最大片段数
+	maxToolTopK = 20
+)
+
+// ErrKnowledgeBaseNotFound 知识库不存在或不属于当前用户
+var ErrKnowledgeBaseNotFound = errors.New("knowledge base not found")
+
+// knowledgeBaseHit 工具返回给模型的检索片段，带知识库与文档 ID 便于引用
+type knowledgeBaseHit struct {
+	KBID string `json:"kb_id"`
+	models.RetrieveResult
+}
+
+// RegisterKnowledgeBaseTool 注册 search_knowledge_base 工具，需在 tools.InitializeTools 之前调用
+func RegisterKnowledgeBaseTool() error {
+	return tools.Register(&tools.Tool{
+		Name:        KnowledgeBaseToolName,
+		Description: "Search the knowledge bases linked to this conversation. Returns the most relevant passages with their doc_id and doc_name; cite the doc_id of every passage you rely on.",
+		Parameters: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"query": {"type": "string", "description": "Search query"},
+				"kb_id": {"type": "string", "description": "Only search this linked knowledge base, defaults to all of them"},
+				"top_k": {"type": "integer", "description": "Number of passages to return, defaults to 5"}
+			},
+			"required": ["query"]
+		}`),
+		Handler: searchKnowledgeBase,
+		Available: func(conversation *models.Conversation) bool {
+			return len(conversation.KBIDs) > 0
+		},
+	})
+}
+
+// searchKnowledgeBase 检索会话关联的知识库，合并结果后按相关度返回前 top_k 个片段
+func searchKnowledgeBase(ctx context.Context, conversation *models.Conversation, arguments json.RawMessage) (string, error) {
+	var args struct {
+		Query string `json:"query"`
+		KBID  string `json:"kb_id"`
+		TopK  int    `json:"top_k"`
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+	if args.Query == "" {
+		return "", errors.New("query is required")
+	}
+	if args.TopK <= 0 {
+		args.TopK = DefaultTopK
+	}
+	if args.TopK > maxToolTopK {
+		args.TopK = maxToolTopK
+	}
+
+	kbIDs := conversation.KBIDs
+	if args.KBID != "" {
+		if !containsString(kbIDs, args.KBID) {
+			return "", fmt.Errorf("knowledge base %s is not linked to this conversation", args.KBID)
+		}
+		kbIDs = []string{args.KBID}
+	}
+
+	ragService, err := GetRAGService()
+	if err != nil {
+		return "", err
+	}
+	hits := make([]knowledgeBaseHit, 0)
+	for _, kbID := range kbIDs {
+		resp, err := ragService.RetrieveInfo(ctx, kbID, args.Query, args.TopK)
+		if err != nil {
+			return "", err
+		}
+		if !resp.Success {
+			return "", fmt.Errorf("failed to search knowledge base %s: %s", kbID, resp.Message)
+		}
+		for _, result := range resp.Results {
+			hits = append(hits, knowledgeBaseHit{KBID: kbID, RetrieveResult: result})
+		}
+	}
+
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].Score > hits[j].Score })
+	if len(hits) > args.TopK {
+		hits = hits[:args.TopK]
+	}
+	output, err := json.Marshal(map[string]interface{}{"results": hits})
+	if err != nil {
+		return "", err
+	}
+	return string(output), nil
+}
+
+// UsesKnowledgeBaseTool 会话已关联该知识库且启用了检索工具时返回 true，此时由模型自行决定是否检索
+func UsesKnowledgeBaseTool(conversationID int64, kbID string) bool {
+	if !tools.Enabled(KnowledgeBaseToolName) {
+		return false
+	}
+	conversation, err := storage.GetConversationFromRedis(conversationID)
+	if err != nil || conversation == nil {
+		return false
+	}
+	return containsString(conversation.KBIDs, kbID)
+}
+
+// UpdateConversationKnowledgeBases 更新 userID 的会话关联的知识库，kbIDs 为空时取消关联；会话不存在或属于他人时返回 ErrConversationNotFound
+func UpdateConversationKnowledgeBases(userID, conversationID int64, kbIDs []string) ([]string, error) {
+	// 与发送消息等请求互斥，避免整块读改写互相覆盖
+	lock, err := lockConversation(conversationID)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.unlock()
+
+	// 会话与知识库都须属于该用户
+	conversation, err := getUserConversation(userID, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	lock.attach(conversation)
+	if err := checkKnowledgeBases(userID, kbIDs); err != nil {
+		return nil, err
+	}
+
+	conversation.KBIDs = kbIDs
+	if err := saveConversation(conversation); err != nil {
+		return nil, fmt.Errorf("failed to save conversation to redis: %w", err)
+	}
+	return conversation.KBIDs, nil
+}
+
+// checkKnowledgeBases 校验知识库均属于该用户
+func checkKnowledgeBases(userID int64, kbIDs []string) error {
+	if len(kbIDs) == 0 {
+		return nil
+	}
+	ragService, err := GetRAGService()
+	if err != nil {
+		return err
+	}
+	resp, err := ragService.ListKnowledgeBases(strconv.FormatInt(userID, 10))
+	if err != nil {
+		return err
+	}
+	if !resp.Success {
+		return errors.New(resp.Message)
+	}
+
+	owned := make(map[string]bool, len(resp.KBs))
+	for _, kb := range resp.KBs {
+		owned[kb.ID] = true
+	}
+	for _, kbID := range kbIDs {
+		if !owned[kbID] {
+			return fmt.Errorf("%w: %s", ErrKnowledgeBaseNotFound, kbID)
+		}
+	}
+	return nil
+}
+
+// containsString 判断切片中是否包含指定字符串
+func containsString(values []string, target string) bool {
+	for _, value := range values {
+		if value == target {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/EthanGuo-coder/llm-backend-api/models"
+	"github.com/EthanGuo-coder/llm-backend-api/storage"
+)
+
+// ErrMessageNotFound 选中的分支或消息树中没有指定的消息
+var ErrMessageNotFound = errors.New("message not found")
+
+// StreamRegenerateMessage 流式重新生成 messageID 所属的回复，原回复保留为另一个分支，生成失败时仍选中原回复
+func StreamRegenerateMessage(c *gin.Context, userID, conversationID int64, messageID int32, req *models.RegenerateReq) error {
+	// 同一会话同时只允许一个请求读改写，生成结束前一直持有
+	lock, err := lockConversation(conversationID)
+	if err != nil {
+		return err
+	}
+	defer lock.unlock()
+
+	conversation, params, err := prepareRegenerate(lock, userID, conversationID, messageID, req)
+	if err != nil {
+		return err
+	}
+	return streamAnswer(c, lock, userID, conversation, params)
+}
+
+// RegenerateMessage 非流式重新生成 messageID 所属的回复，原回复保留为另一个分支，生成失败时仍选中原回复
+func RegenerateMessage(ctx context.Context, userID, conversationID int64, messageID int32, req *models.RegenerateReq) (*models.ChatResponse, error) {
+	// 同一会话同时只允许一个请求读改写，生成结束前一直持有
+	lock, err := lockConversation(conversationID)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.unlock()
+
+	conversation, params, err := prepareRegenerate(lock, userID, conversationID, messageID, req)
+	if err != nil {
+		return nil, err
+	}
+	return completeAnswer(ctx, lock, userID, conversation, params)
+}
+
+// prepareRegenerate 将选中分支切换到 messageID 所属回复对应的用户消息，messageID 可以在任意分支中。
+// 此处不保存会话：新回复保存时才在该用户消息下成为新的分支，生成失败时原回复仍是选中的分支
+func prepareRegenerate(lock *conversationLock, userID, conversationID int64, messageID int32, req *models.RegenerateReq) (*models.Conversation, *models.GenerationParams, error) {
+	conversation, params, err := loadConversation(lock, userID, conversationID, req.Params)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	index := make(map[int32]int, len(conversation.Tree))
+	for i, message := range conversation.Tree {
+		index[message.MessageID] = i
+	}
+	i, ok := index[messageID]
+	if !ok || conversation.Tree[i].Role != "assistant" {
+		return nil, nil, fmt.Errorf("%w: no assistant message %d in the conversation", ErrMessageNotFound, messageID)
+	}
+	// 沿父消息向上找到该回复所属的用户消息，步数不超过消息总数，避免损坏的父子关系导致死循环
+	for steps := 0; conversation.Tree[i].Role != "user"; steps++ {
+		if i, ok = index[conversation.Tree[i].ParentID]; !ok || steps >= len(conversation.Tree) {
+			return nil, nil, fmt.Errorf("%w: no user message before assistant message %d", ErrMessageNotFound, messageID)
+		}
+	}
+
+	conversation.Messages = storage.MessagePath(conversation.Tree, conversation.Tree[i].MessageID)
+	return conversation, params, nil
+}
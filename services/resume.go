@@ -0,0 +1,76 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/EthanGuo-coder/llm-backend-api/config"
+	"github.com/EthanGuo-coder/llm-backend-api/storage"
+)
+
+// ErrGenerationNotFound 没有可续传的生成，或生成的事件流已过期
+var ErrGenerationNotFound = errors.New("generation not found or expired")
+
+// ResumeStream 断线续传：先回放 userID 的会话中 lastEventID 之后的事件，再跟随仍在进行的生成，直到结束。
+// generationID 为空时续传会话最近一次生成，lastEventID 为空时从头回放。
+func ResumeStream(c *gin.Context, userID, conversationID int64, generationID, lastEventID string) error {
+	if err := checkConversationOwner(userID, conversationID); err != nil {
+		return err
+	}
+	if generationID == "" {
+		latest, err := storage.GetLatestGeneration(conversationID)
+		if err != nil {
+			return err
+		}
+		generationID = latest
+	}
+	if generationID == "" {
+		return ErrGenerationNotFound
+	}
+	exists, err := storage.GenerationExists(conversationID, generationID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrGenerationNotFound
+	}
+
+	if lastEventID == "" {
+		lastEventID = "0"
+	}
+	idleTimeout := time.Duration(config.AppConfig.Stream.IdleTimeout) * time.Second
+	ctx := c.Request.Context()
+
+	w := newSSEWriter(c)
+	w.c.Writer.Header().Set("X-Generation-ID", generationID)
+	for {
+		events, err := storage.ReadGenerationEvents(ctx, conversationID, generationID, lastEventID, idleTimeout)
+		if err != nil {
+			// 客户端断开时 ctx 被取消，直接结束
+			if ctx.Err() != nil {
+				return nil
+			}
+			// 响应头已按 SSE 发出，错误只能作为事件推送；沿用已回放的事件 ID，客户端可从原位置再次续传
+			log.Printf("conversation %d: failed to read generation %s: %v", conversationID, generationID, err)
+			payload, _ := json.Marshal(err.Error())
+			w.write(lastEventID, "error", payload)
+			return nil
+		}
+		// 超过等待时间仍无新事件，生成方可能已异常退出
+		if len(events) == 0 {
+			return nil
+		}
+
+		for _, event := range events {
+			if event.Event == streamEndEvent {
+				return nil
+			}
+			w.write(event.ID, event.Event, event.Data)
+			lastEventID = event.ID
+		}
+	}
+}
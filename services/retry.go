@@ -0,0 +1,77 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/EthanGuo-coder/llm-backend-api/config"
+	"github.com/EthanGuo-coder/llm-backend-api/providers"
+)
+
+// retryEvent 重试事件，通过 SSE retry 事件通知客户端
+type retryEvent struct {
+	Model   string `json:"model"`
+	Attempt int    `json:"attempt"` // 即将进行的第几次尝试
+	DelayMs int64  `json:"delay_ms"`
+	Reason  string `json:"reason"`
+}
+
+// openWithRetry 按重试策略对单个模型建立上游连接。
+// 只重试请求发送与状态校验阶段，用户消息已在此之前写入，重试不会重复追加。
+func openWithRetry(ctx context.Context, conversationID int64, provider providers.Provider, chatReq *providers.ChatRequest, events *[]upstreamEvent) (*http.Response, error) {
+	maxAttempts := config.AppConfig.Retry.MaxAttempts
+
+	for attempt := 1; ; attempt++ {
+		resp, err := sendAPIRequest(ctx, provider, chatReq)
+		if err == nil {
+			if err = validateResponse(provider, resp); err == nil {
+				return resp, nil
+			}
+			resp.Body.Close()
+		}
+
+		if !isTransient(err) || attempt >= maxAttempts {
+			return nil, err
+		}
+		delay, ok := retryDelay(attempt, err)
+		if !ok {
+			return nil, err
+		}
+
+		log.Printf("conversation %d: model %s attempt %d failed, retrying in %v: %v", conversationID, chatReq.Model, attempt, delay, err)
+		*events = append(*events, upstreamEvent{
+			Event: "retry",
+			Data:  retryEvent{Model: chatReq.Model, Attempt: attempt + 1, DelayMs: delay.Milliseconds(), Reason: err.Error()},
+		})
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// retryDelay 计算第 attempt 次失败后的等待时间。
+// 优先遵循 Retry-After，超过上限时放弃重试；否则使用带抖动的指数退避。
+func retryDelay(attempt int, err error) (time.Duration, bool) {
+	policy := config.AppConfig.Retry
+
+	var apiErr *providers.APIError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+		if apiErr.RetryAfter > time.Duration(policy.MaxRetryAfter)*time.Second {
+			return 0, false
+		}
+		return apiErr.RetryAfter, true
+	}
+
+	backoff := float64(policy.InitialBackoff) * math.Pow(policy.Multiplier, float64(attempt-1))
+	backoff = math.Min(backoff, float64(policy.MaxBackoff))
+	// 等量抖动：一半固定，一半随机，避免多个实例同时重试
+	jittered := backoff/2 + rand.Float64()*backoff/2
+	return time.Duration(jittered) * time.Millisecond, true
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/EthanGuo-coder/llm-backend-api/config"
+	"github.com/EthanGuo-coder/llm-backend-api/storage"
+)
+
+// streamEndEvent 写入事件流的结束标记，仅用于续传时判断生成已结束，不推送给客户端
+const streamEndEvent = "end"
+
+// sseWriter 向客户端输出流式事件。
+// 默认输出兼容旧客户端的 {"id","event","data"} JSON 对象；
+// 标准模式输出带 id 的 event:/data: 帧，可被 EventSource 等标准 SSE 客户端消费。
+// 开启记录后，每个事件同时写入 Redis Stream，事件 ID 即条目 ID，供断线续传。
+type sseWriter struct {
+	c        *gin.Context
+	standard bool
+	seq      int64
+
+	conversationID int64
+	generationID   string // 为空时不记录
+}
+
+// newSSEWriter 设置 SSE 响应头，并根据 Accept 头或 format 参数协商输出格式
+func newSSEWriter(c *gin.Context) *sseWriter {
+	setSSEHeaders(c)
+	return &sseWriter{c: c, standard: wantsStandardSSE(c)}
+}
+
+// wantsStandardSSE 请求 format=sse 或 Accept 含 text/event-stream 时使用标准 SSE 帧
+func wantsStandardSSE(c *gin.Context) bool {
+	switch c.Query("format") {
+	case "sse":
+		return true
+	case "json":
+		return false
+	}
+	return strings.Contains(c.GetHeader("Accept"), "text/event-stream")
+}
+
+// setSSEHeaders 设置 SSE 响应头
+func setSSEHeaders(c *gin.Context) {
+	c.Writer.Header().Set("Content-Type", "text/event-stream")
+	c.Writer.Header().Set("Cache-Control", "no-cache")
+	c.Writer.Header().Set("Connection", "keep-alive")
+}
+
+// newGenerationID 生成单次生成的 ID
+func newGenerationID() string {
+	return strconv.FormatInt(time.Now().UnixNano(), 10)
+}
+
+// record 开启事件记录，并通过 X-Generation-ID 响应头告知客户端续传所需的生成 ID
+func (w *sseWriter) record(conversationID int64, generationID string) {
+	w.conversationID = conversationID
+	w.generationID = generationID
+	w.c.Writer.Header().Set("X-Generation-ID", generationID)
+
+	if err := storage.SetLatestGeneration(conversationID, generationID, streamTTL()); err != nil {
+		log.Printf("conversation %d: failed to record latest generation: %v", conversationID, err)
+	}
+}
+
+// send 发送一个事件，data 统一经过 JSON 编码；w 为空（非流式请求）时忽略
+func (w *sseWriter) send(event string, data interface{}) {
+	if w == nil {
+		return
+	}
+	payload, _ := json.Marshal(data)
+	w.seq++
+	id := strconv.FormatInt(w.seq, 10)
+
+	if w.generationID != "" {
+		streamID, err := storage.AppendGenerationEvent(w.conversationID, w.generationID, event, payload, streamTTL())
+		if err != nil {
+			log.Printf("conversation %d: %v", w.conversationID, err)
+		} else {
+			id = streamID
+		}
+	}
+	w.write(id, event, payload)
+}
+
+// write 按协商的格式输出一个已编码的事件
+func (w *sseWriter) write(id, event string, payload json.RawMessage) {
+	if w.standard {
+		fmt.Fprintf(w.c.Writer, "id: %s\nevent: %s\ndata: %s\n\n", id, event, payload)
+	} else {
+		message, _ := json.Marshal(map[string]interface{}{
+			"id":    id,
+			"event": event,
+			"data":  payload,
+		})
+		fmt.Fprintf(w.c.Writer, "%s\n\n", message)
+	}
+	w.c.Writer.Flush()
+}
+
+// close 写入结束标记，续传方读到后停止等待
+func (w *sseWriter) close() {
+	if w.generationID == "" {
+		return
+	}
+	if _, err := storage.AppendGenerationEvent(w.conversationID, w.generationID, streamEndEvent, []byte("null"), streamTTL()); err != nil {
+		log.Printf("conversation %d: %v", w.conversationID, err)
+	}
+}
+
+// streamTTL 事件流保留时间
+func streamTTL() time.Duration {
+	return time.Duration(config.AppConfig.Stream.TTL) * time.Second
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+
+	"github.com/EthanGuo-coder/llm-backend-api/storage"
+)
+
+var (
+	// ErrGenerationStopped 生成被停止接口中止
+	ErrGenerationStopped = errors.New("generation stopped")
+	// ErrNoActiveGeneration 会话中没有进行中的生成
+	ErrNoActiveGeneration = errors.New("no generation in progress")
+)
+
+// StopGeneration 通过 Redis Pub/Sub 通知持有生成的实例停止 userID 的会话中的生成，多实例部署时同样有效。
+// generationID 为空时停止会话中全部进行中的生成
+func StopGeneration(userID, conversationID int64, generationID string) error {
+	if err := checkConversationOwner(userID, conversationID); err != nil {
+		return err
+	}
+	// 生成期间一直持有会话写锁，锁未被持有时没有进行中的生成
+	locked, err := storage.ConversationLocked(conversationID)
+	if err != nil {
+		return err
+	}
+	if !locked {
+		return ErrNoActiveGeneration
+	}
+	if generationID != "" {
+		latest, err := storage.GetLatestGeneration(conversationID)
+		if err != nil {
+			return err
+		}
+		if latest != generationID {
+			return ErrNoActiveGeneration
+		}
+	}
+	return storage.PublishStopSignal(conversationID, generationID)
+}
+
+// stopWatch 本实例中一次进行中的生成
+type stopWatch struct {
+	generationID string
+	cancel       context.CancelCauseFunc
+}
+
+// stopDispatcher 每个实例只有一个的停止信号订阅，按会话将信号分发给本实例中进行中的生成，
+// 避免每次生成各占用一个 Redis 订阅连接
+type stopDispatcher struct {
+	mu      sync.Mutex
+	started bool
+	next    int
+	watches map[int64]map[int]stopWatch // 会话 ID -> 登记序号 -> 生成
+}
+
+var stops = &stopDispatcher{watches: make(map[int64]map[int]stopWatch)}
+
+// watch 登记进行中的生成，首次调用时启动订阅，订阅失败时下次调用重试；返回注销函数
+func (d *stopDispatcher) watch(conversationID int64, generationID string, cancel context.CancelCauseFunc) (func(), error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.started {
+		signals, err := storage.SubscribeStopSignals(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		d.started = true
+		go d.dispatch(signals)
+	}
+
+	d.next++
+	id := d.next
+	if d.watches[conversationID] == nil {
+		d.watches[conversationID] = make(map[int]stopWatch)
+	}
+	d.watches[conversationID][id] = stopWatch{generationID: generationID, cancel: cancel}
+	return func() {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		delete(d.watches[conversationID], id)
+		if len(d.watches[conversationID]) == 0 {
+			delete(d.watches, conversationID)
+		}
+	}, nil
+}
+
+// dispatch 取消收到的信号所针对的生成（生成 ID 为空时取消会话中全部生成）。
+// 订阅关闭后重置状态，下一次 watch 重新订阅
+func (d *stopDispatcher) dispatch(signals <-chan storage.StopSignal) {
+	for signal := range signals {
+		d.mu.Lock()
+		for _, w := range d.watches[signal.ConversationID] {
+			if signal.GenerationID == "" || signal.GenerationID == w.generationID {
+				w.cancel(ErrGenerationStopped)
+			}
+		}
+		d.mu.Unlock()
+	}
+
+	log.Printf("stop signal subscription closed, resubscribing on the next generation")
+	d.mu.Lock()
+	d.started = false
+	d.mu.Unlock()
+}
+
+// watchStop 在生成期间登记到停止信号分发，收到针对本次生成（或全部生成）的信号时取消返回的 ctx。
+// 订阅失败时只记录日志，生成照常进行但无法被停止；生成结束后须调用返回的函数注销
+func watchStop(ctx context.Context, conversationID int64, generationID string) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	unwatch, err := stops.watch(conversationID, generationID, cancel)
+	if err != nil {
+		log.Printf("conversation %d: %v", conversationID, err)
+		return ctx, func() { cancel(nil) }
+	}
+	return ctx, func() {
+		unwatch()
+		cancel(nil)
+	}
+}
+
+// stopCause 生成因停止信号或会话写锁丢失中断时将 err 替换为 ErrGenerationStopped 或 ErrConversationLockLost
+func stopCause(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	if cause := context.Cause(ctx); errors.Is(cause, ErrGenerationStopped) || errors.Is(cause, ErrConversationLockLost) {
+		return cause
+	}
+	return err
+}
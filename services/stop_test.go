@@ -0,0 +1,57 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/EthanGuo-coder/llm-backend-api/storage"
+)
+
+func TestStopDispatcher(t *testing.T) {
+	d := &stopDispatcher{started: true, watches: make(map[int64]map[int]stopWatch)}
+	register := func(conversationID int64, generationID string) context.Context {
+		ctx, cancel := context.WithCancelCause(context.Background())
+		d.next++
+		if d.watches[conversationID] == nil {
+			d.watches[conversationID] = make(map[int]stopWatch)
+		}
+		d.watches[conversationID][d.next] = stopWatch{generationID: generationID, cancel: cancel}
+		return ctx
+	}
+	target := register(1, "a")
+	sibling := register(1, "b")
+	other := register(2, "a")
+	all := register(3, "c")
+
+	signals := make(chan storage.StopSignal)
+	done := make(chan struct{})
+	go func() {
+		d.dispatch(signals)
+		close(done)
+	}()
+	signals <- storage.StopSignal{ConversationID: 1, GenerationID: "a"}
+	signals <- storage.StopSignal{ConversationID: 3}
+	close(signals)
+	<-done
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		stopped bool
+	}{
+		{"targeted generation", target, true},
+		{"other generation in the same conversation", sibling, false},
+		{"same generation ID in another conversation", other, false},
+		{"whole conversation", all, true},
+	}
+	for _, tt := range tests {
+		if got := errors.Is(context.Cause(tt.ctx), ErrGenerationStopped); got != tt.stopped {
+			t.Errorf("%s: stopped = %v, want %v", tt.name, got, tt.stopped)
+		}
+	}
+	// 订阅关闭后下一次 watch 须重新订阅
+	if d.started {
+		t.Error("dispatcher still marked as started after the subscription closed")
+	}
+}
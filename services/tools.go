@@ -0,0 +1,49 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/EthanGuo-coder/llm-backend-api/models"
+	"github.com/EthanGuo-coder/llm-backend-api/tools"
+)
+
+// runTools 保存模型发起的工具调用，依次执行并把结果作为 tool 消息追加到会话。
+// 工具执行失败时把错误信息交给模型，由模型决定如何继续。
+func runTools(ctx context.Context, w *sseWriter, conversation *models.Conversation, result *streamResult) error {
+	// 部分服务商（如 Ollama）不提供调用 ID，按消息位置生成
+	for i := range result.toolCalls {
+		if result.toolCalls[i].ID == "" {
+			result.toolCalls[i].ID = fmt.Sprintf("call_%d_%d", len(conversation.Messages), i)
+		}
+	}
+	assistant := models.Message{Role: "assistant", Content: result.content, Model: result.model, ToolCalls: result.toolCalls}
+	if err := saveConversationWithAIResponse(conversation, assistant); err != nil {
+		return fmt.Errorf("failed to save tool calls: %w", err)
+	}
+
+	for _, call := range result.toolCalls {
+		w.send("tool_call", call)
+
+		toolResult := models.ToolResult{ID: call.ID, Name: call.Name}
+		output, err := tools.Execute(ctx, conversation, call)
+		if err != nil {
+			// 客户端断开导致的失败不再交给模型
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			log.Printf("conversation %d: tool %s failed: %v", conversation.ID, call.Name, err)
+			output = "error: " + err.Error()
+			toolResult.IsError = true
+		}
+		toolResult.Content = output
+
+		message := models.Message{Role: "tool", Content: output, ToolCallID: call.ID, Name: call.Name}
+		if err := saveConversationWithAIResponse(conversation, message); err != nil {
+			return fmt.Errorf("failed to save tool result: %w", err)
+		}
+		w.send("tool_result", toolResult)
+	}
+	return nil
+}
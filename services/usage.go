@@ -0,0 +1,79 @@
+package services
+
+import (
+	"log"
+	"time"
+
+	"github.com/EthanGuo-coder/llm-backend-api/billing"
+	"github.com/EthanGuo-coder/llm-backend-api/constant"
+	"github.com/EthanGuo-coder/llm-backend-api/models"
+	"github.com/EthanGuo-coder/llm-backend-api/storage"
+)
+
+// recordUsage 将回复的用量写入 SQLite 以便汇总并计入配额，上游未报告用量时跳过；失败只记录日志，不影响回复
+func recordUsage(userID, conversationID int64, message models.Message) {
+	if message.Usage == nil {
+		return
+	}
+	record := &models.UsageRecord{
+		Kind:             constant.UsageKindReply,
+		UserID:           userID,
+		ConversationID:   conversationID,
+		MessageID:        message.MessageID,
+		Provider:         billing.ProviderName(message.Model),
+		Model:            message.Model,
+		PromptTokens:     message.Usage.PromptTokens,
+		CompletionTokens: message.Usage.CompletionTokens,
+		TotalTokens:      message.Usage.TotalTokens,
+		LatencyMs:        message.LatencyMs,
+		TTFTMs:           message.TTFTMs,
+		Cost:             message.Cost,
+		CreatedTime:      time.Now().Unix(),
+	}
+	if err := storage.SaveUsageRecord(record); err != nil {
+		log.Printf("conversation %d: failed to record usage: %v", conversationID, err)
+	}
+	addQuotaUsage(userID, message.Usage.TotalTokens, message.Cost)
+}
+
+// recordSummaryUsage 将上下文摘要或滚动摘要调用的用量写入 SQLite 并计入配额，记录不关联消息；失败只记录日志
+func recordSummaryUsage(conversation *models.Conversation, model string, usage *models.Usage) {
+	if usage == nil || conversation.UserID == 0 {
+		return
+	}
+	cost, _ := billing.Cost(model, usage)
+	record := &models.UsageRecord{
+		Kind:             constant.UsageKindSummary,
+		UserID:           conversation.UserID,
+		ConversationID:   conversation.ID,
+		Provider:         billing.ProviderName(model),
+		Model:            model,
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		TotalTokens:      usage.TotalTokens,
+		Cost:             cost,
+		CreatedTime:      time.Now().Unix(),
+	}
+	if err := storage.SaveUsageRecord(record); err != nil {
+		log.Printf("conversation %d: failed to record summary usage: %v", conversation.ID, err)
+	}
+	addQuotaUsage(conversation.UserID, usage.TotalTokens, cost)
+}
+
+// GetUserUsage 获取用户的用量总计与按会话的明细
+func GetUserUsage(userID int64) (*models.UserUsageResp, error) {
+	total, err := storage.GetUserUsageFromDB(userID)
+	if err != nil {
+		return nil, err
+	}
+	conversations, err := storage.GetUserUsageByConversationFromDB(userID)
+	if err != nil {
+		return nil, err
+	}
+	return &models.UserUsageResp{Total: *total, Conversations: conversations}, nil
+}
+
+// GetConversationUsage 获取用户在单个会话中的用量
+func GetConversationUsage(userID, conversationID int64) (*models.UsageSummary, error) {
+	return storage.GetConversationUsageFromDB(userID, conversationID)
+}
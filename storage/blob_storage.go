@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/EthanGuo-coder/llm-backend-api/config"
+)
+
+// BlobStore 文件内容存储，可替换为对象存储实现
+type BlobStore interface {
+	Put(key string, data []byte) error
+	Get(key string) ([]byte, error)
+	Delete(key string) error
+}
+
+// LocalBlobStore 将文件内容保存在本地目录
+type LocalBlobStore struct {
+	dir string
+}
+
+var blobStore BlobStore
+
+// InitializeBlobStore 初始化 Blob 存储，默认使用本地磁盘
+func InitializeBlobStore() error {
+	dir := config.AppConfig.Files.Dir
+	if err := ensureDirectoryExists(dir); err != nil {
+		return fmt.Errorf("failed to ensure blob directory exists: %w", err)
+	}
+	blobStore = &LocalBlobStore{dir: dir}
+	return nil
+}
+
+// SetBlobStore 替换 Blob 存储实现
+func SetBlobStore(store BlobStore) {
+	blobStore = store
+}
+
+// path 返回 key 对应的本地路径，key 中的路径分隔符会被去掉
+func (s *LocalBlobStore) path(key string) string {
+	return filepath.Join(s.dir, filepath.Base(key))
+}
+
+func (s *LocalBlobStore) Put(key string, data []byte) error {
+	return os.WriteFile(s.path(key), data, 0o644)
+}
+
+func (s *LocalBlobStore) Get(key string) ([]byte, error) {
+	return os.ReadFile(s.path(key))
+}
+
+func (s *LocalBlobStore) Delete(key string) error {
+	if err := os.Remove(s.path(key)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// PutBlob 保存文件内容
+func PutBlob(key string, data []byte) error {
+	if err := blobStore.Put(key, data); err != nil {
+		return fmt.Errorf("failed to store blob: %w", err)
+	}
+	return nil
+}
+
+// GetBlob 读取文件内容
+func GetBlob(key string) ([]byte, error) {
+	data, err := blobStore.Get(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blob: %w", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"github.com/EthanGuo-coder/llm-backend-api/models"
+)
+
+// conversationSchemaTree 消息树存储格式：messages 保存全部分支，每条消息记录父消息 ID，leaf_id 为选中分支的末尾
+const conversationSchemaTree = 1
+
+// restoreTree 读取会话后还原选中的分支，旧版线性会话先迁移为消息树
+func restoreTree(conversation *models.Conversation) {
+	if conversation.Schema < conversationSchemaTree {
+		migrateLinear(conversation)
+	}
+	conversation.Messages = MessagePath(conversation.Tree, conversation.LeafID)
+}
+
+// migrateLinear 将线性会话迁移为消息树：消息依次以前一条为父消息，整条线性历史为选中分支
+func migrateLinear(conversation *models.Conversation) {
+	for i := range conversation.Tree {
+		if i > 0 {
+			conversation.Tree[i].ParentID = conversation.Tree[i-1].MessageID
+		}
+	}
+	if len(conversation.Tree) > 0 {
+		conversation.LeafID = conversation.Tree[len(conversation.Tree)-1].MessageID
+	}
+	conversation.Schema = conversationSchemaTree
+}
+
+// mergeTree 将选中分支合并回消息树：分支中的消息以前一条为父消息，已有的消息原位更新，新消息追加在末尾
+func mergeTree(conversation *models.Conversation) {
+	index := make(map[int32]int, len(conversation.Tree))
+	for i, message := range conversation.Tree {
+		index[message.MessageID] = i
+	}
+	for i := range conversation.Messages {
+		if i > 0 {
+			conversation.Messages[i].ParentID = conversation.Messages[i-1].MessageID
+		}
+		message := conversation.Messages[i]
+		message.Branches = nil
+		if j, ok := index[message.MessageID]; ok {
+			conversation.Tree[j] = message
+			continue
+		}
+		index[message.MessageID] = len(conversation.Tree)
+		conversation.Tree = append(conversation.Tree, message)
+	}
+	if len(conversation.Messages) > 0 {
+		conversation.LeafID = conversation.Messages[len(conversation.Messages)-1].MessageID
+	}
+	conversation.Schema = conversationSchemaTree
+}
+
+// MessagePath 返回消息树中从根消息（首条消息）到 leafID 的路径，leafID 不存在时取最后一条消息
+func MessagePath(tree []models.Message, leafID int32) []models.Message {
+	if len(tree) == 0 {
+		return nil
+	}
+	index := make(map[int32]int, len(tree))
+	for i, message := range tree {
+		index[message.MessageID] = i
+	}
+	i, ok := index[leafID]
+	if !ok {
+		i = len(tree) - 1
+	}
+
+	var reversed []models.Message
+	rootID := tree[0].MessageID
+	// 路径长度不超过消息总数，避免损坏的父子关系导致死循环
+	for len(reversed) < len(tree) {
+		reversed = append(reversed, tree[i])
+		if tree[i].MessageID == rootID {
+			break
+		}
+		if i, ok = index[tree[i].ParentID]; !ok {
+			break
+		}
+	}
+
+	path := make([]models.Message, len(reversed))
+	for j, message := range reversed {
+		path[len(reversed)-1-j] = message
+	}
+	return path
+}
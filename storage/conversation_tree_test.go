@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/EthanGuo-coder/llm-backend-api/models"
+)
+
+// msg 构造指定 ID、角色与父消息的消息
+func msg(id int32, role string, parentID int32) models.Message {
+	return models.Message{MessageID: id, Role: role, Content: role, ParentID: parentID}
+}
+
+// ids 返回消息的 ID 序列，便于比较路径
+func ids(messages []models.Message) []int32 {
+	result := make([]int32, len(messages))
+	for i, message := range messages {
+		result[i] = message.MessageID
+	}
+	return result
+}
+
+// parents 返回消息 ID 到父消息 ID 的映射
+func parents(messages []models.Message) map[int32]int32 {
+	result := make(map[int32]int32, len(messages))
+	for _, message := range messages {
+		result[message.MessageID] = message.ParentID
+	}
+	return result
+}
+
+func TestRestoreTreeLegacy(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		leaf    int32
+		path    []int32
+		parents map[int32]int32
+	}{
+		{"empty", `{"conversation_id": 1, "messages": []}`, 0, []int32{}, map[int32]int32{}},
+		{"linear",
+			`{"conversation_id": 1, "messages": [
+				{"message_id": 1, "role": "system"},
+				{"message_id": 2, "role": "user"},
+				{"message_id": 3, "role": "assistant"}
+			]}`,
+			3, []int32{1, 2, 3}, map[int32]int32{1: 0, 2: 1, 3: 2}},
+		{"alternatives are ignored",
+			`{"conversation_id": 1, "messages": [
+				{"message_id": 1, "role": "user"},
+				{"message_id": 4, "role": "assistant", "alternatives": [{"message_id": 2, "role": "assistant"}]},
+				{"message_id": 5, "role": "user"}
+			]}`,
+			5, []int32{1, 4, 5}, map[int32]int32{1: 0, 4: 1, 5: 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var conversation models.Conversation
+			if err := json.Unmarshal([]byte(tt.data), &conversation); err != nil {
+				t.Fatalf("unmarshal: %v", err)
+			}
+			restoreTree(&conversation)
+			if conversation.Schema != conversationSchemaTree {
+				t.Errorf("schema = %d, want %d", conversation.Schema, conversationSchemaTree)
+			}
+			if conversation.LeafID != tt.leaf {
+				t.Errorf("leaf = %d, want %d", conversation.LeafID, tt.leaf)
+			}
+			if got := ids(conversation.Messages); !reflect.DeepEqual(got, tt.path) {
+				t.Errorf("path = %v, want %v", got, tt.path)
+			}
+			if got := parents(conversation.Tree); !reflect.DeepEqual(got, tt.parents) {
+				t.Errorf("parents = %v, want %v", got, tt.parents)
+			}
+		})
+	}
+}
+
+func TestMergeTree(t *testing.T) {
+	tree := func() []models.Message {
+		return []models.Message{msg(1, "user", 0), msg(2, "assistant", 1), msg(3, "user", 2), msg(4, "assistant", 3)}
+	}
+	tests := []struct {
+		name     string
+		messages []models.Message
+		leaf     int32
+		parents  map[int32]int32
+	}{
+		{"append to the selected branch",
+			append(tree(), msg(5, "user", 0), msg(6, "assistant", 0)),
+			6, map[int32]int32{1: 0, 2: 1, 3: 2, 4: 3, 5: 4, 6: 5}},
+		{"regenerate a reply",
+			append(tree()[:3], msg(5, "assistant", 0)),
+			5, map[int32]int32{1: 0, 2: 1, 3: 2, 4: 3, 5: 3}},
+		{"edit the first user message",
+			[]models.Message{msg(5, "user", 0), msg(6, "assistant", 0)},
+			6, map[int32]int32{1: 0, 2: 1, 3: 2, 4: 3, 5: 0, 6: 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conversation := models.Conversation{Tree: tree(), LeafID: 4, Messages: tt.messages}
+			mergeTree(&conversation)
+			if conversation.LeafID != tt.leaf {
+				t.Errorf("leaf = %d, want %d", conversation.LeafID, tt.leaf)
+			}
+			if got := parents(conversation.Tree); !reflect.DeepEqual(got, tt.parents) {
+				t.Errorf("parents = %v, want %v", got, tt.parents)
+			}
+			// 合并后从消息树还原的选中分支与合并前一致
+			if got, want := ids(MessagePath(conversation.Tree, conversation.LeafID)), ids(tt.messages); !reflect.DeepEqual(got, want) {
+				t.Errorf("path = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestMessagePath(t *testing.T) {
+	// 1 ─ 2 ─ 3 ─ 4
+	//         └ 5 ─ 6
+	// 7 为编辑首条消息产生的兄弟分支
+	tree := []models.Message{
+		msg(1, "user", 0), msg(2, "assistant", 1), msg(3, "user", 2), msg(4, "assistant", 3),
+		msg(5, "assistant", 3), msg(6, "user", 5), msg(7, "user", 0),
+	}
+	tests := []struct {
+		name string
+		tree []models.Message
+		leaf int32
+		want []int32
+	}{
+		{"empty tree", nil, 1, nil},
+		{"selected branch", tree, 4, []int32{1, 2, 3, 4}},
+		{"sibling branch", tree, 6, []int32{1, 2, 3, 5, 6}},
+		{"inner leaf", tree, 3, []int32{1, 2, 3}},
+		{"sibling of the first message", tree, 7, []int32{7}},
+		{"nonexistent leaf falls back to the last message", tree[:6], 42, []int32{1, 2, 3, 5, 6}},
+		{"broken parent chain",
+			[]models.Message{msg(1, "user", 0), msg(2, "assistant", 1), msg(4, "assistant", 3)},
+			4, []int32{4}},
+		{"parent cycle",
+			[]models.Message{msg(1, "user", 0), msg(2, "assistant", 3), msg(3, "user", 2)},
+			3, []int32{3, 2, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := MessagePath(tt.tree, tt.leaf)
+			if tt.want == nil {
+				if path != nil {
+					t.Errorf("path = %v, want nil", ids(path))
+				}
+				return
+			}
+			if got := ids(path); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("path = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
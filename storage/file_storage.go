@@ -0,0 +1,33 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/EthanGuo-coder/llm-backend-api/models"
+)
+
+// SaveFileToDB 保存文件元信息
+func SaveFileToDB(file *models.File) error {
+	db := GetDB()
+	_, err := db.Exec(InsertFile, file.ID, file.UserID, file.Filename, file.MimeType, file.Size, file.HasThumbnail, file.CreatedTime)
+	if err != nil {
+		return errors.New("failed to insert file: " + err.Error())
+	}
+	return nil
+}
+
+// GetFileFromDB 获取文件元信息，不存在时返回 nil
+func GetFileFromDB(fileID string) (*models.File, error) {
+	db := GetDB()
+	var file models.File
+	err := db.QueryRow(FetchFile, fileID).Scan(
+		&file.ID, &file.UserID, &file.Filename, &file.MimeType, &file.Size, &file.HasThumbnail, &file.CreatedTime,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.New("failed to fetch file: " + err.Error())
+	}
+	return &file, nil
+}
@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/EthanGuo-coder/llm-backend-api/models"
+)
+
+// SaveMemoryToDB 保存会话的滚动摘要，已存在时覆盖
+func SaveMemoryToDB(conversationID int64, memory *models.ConversationMemory) error {
+	db := GetDB()
+	_, err := db.Exec(UpsertConversationMemory, conversationID, memory.Summary, memory.ThroughMessageID, memory.Model, memory.UpdatedTime)
+	if err != nil {
+		return errors.New("failed to save memory: " + err.Error())
+	}
+	return nil
+}
+
+// GetMemoryFromDB 获取会话的滚动摘要，不存在时返回 nil
+func GetMemoryFromDB(conversationID int64) (*models.ConversationMemory, error) {
+	db := GetDB()
+	var memory models.ConversationMemory
+	err := db.QueryRow(FetchConversationMemory, conversationID).Scan(
+		&memory.Summary, &memory.ThroughMessageID, &memory.Model, &memory.UpdatedTime,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.New("failed to fetch memory: " + err.Error())
+	}
+	return &memory, nil
+}
+
+// DeleteMemoryFromDB 删除会话的滚动摘要
+func DeleteMemoryFromDB(conversationID int64) error {
+	db := GetDB()
+	if _, err := db.Exec(DeleteConversationMemory, conversationID); err != nil {
+		return errors.New("failed to delete memory: " + err.Error())
+	}
+	return nil
+}
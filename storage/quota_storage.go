@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/EthanGuo-coder/llm-backend-api/models"
+)
+
+// SaveUserQuotaToDB 保存管理员为用户单独设置的配额，已存在时覆盖
+func SaveUserQuotaToDB(userID int64, quota *models.UserQuota) error {
+	db := GetDB()
+	_, err := db.Exec(UpsertUserQuota, userID,
+		quota.Daily.Tokens, quota.Daily.Requests, quota.Daily.Cost,
+		quota.Monthly.Tokens, quota.Monthly.Requests, quota.Monthly.Cost,
+		time.Now().Unix(),
+	)
+	if err != nil {
+		return errors.New("failed to save user quota: " + err.Error())
+	}
+	return nil
+}
+
+// GetUserQuotaFromDB 获取用户单独设置的配额，未设置时返回 nil
+func GetUserQuotaFromDB(userID int64) (*models.UserQuota, error) {
+	db := GetDB()
+	var quota models.UserQuota
+	err := db.QueryRow(FetchUserQuota, userID).Scan(
+		&quota.Daily.Tokens, &quota.Daily.Requests, &quota.Daily.Cost,
+		&quota.Monthly.Tokens, &quota.Monthly.Requests, &quota.Monthly.Cost,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.New("failed to fetch user quota: " + err.Error())
+	}
+	return &quota, nil
+}
+
+// DeleteUserQuotaFromDB 删除用户单独设置的配额，恢复为默认配额
+func DeleteUserQuotaFromDB(userID int64) error {
+	db := GetDB()
+	if _, err := db.Exec(DeleteUserQuota, userID); err != nil {
+		return errors.New("failed to delete user quota: " + err.Error())
+	}
+	return nil
+}
+
+// UserExists 判断用户是否存在
+func UserExists(userID int64) (bool, error) {
+	db := GetDB()
+	var count int
+	if err := db.QueryRow(CountUser, userID).Scan(&count); err != nil {
+		return false, errors.New("failed to fetch user: " + err.Error())
+	}
+	return count > 0, nil
+}
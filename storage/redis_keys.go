@@ -4,8 +4,18 @@ import "fmt"
 
 // RedisKey 用于存储 Redis 的键模板
 const (
-	RedisKeyConversation = "conversation:%d" // 会话的键
-	RedisKeyJWT          = "jwt:%s"          // JWT 的键
+	RedisKeyConversation     = "conversation:%d"      // 会话的键
+	RedisKeyConversationLock = "conversation:lock:%d" // 会话写锁，值为持有者的令牌
+	RedisKeyJWT              = "jwt:%s"               // JWT 的键
+	RedisKeyGeneration       = "generation:%d:%s"     // 单次生成的事件流（Redis Stream）
+	RedisKeyLatestGeneration = "generation:latest:%d" // 会话最近一次生成的 ID
+	RedisChannelStop         = "generation:stop:%d"   // 停止会话中生成的 Pub/Sub 频道，消息为生成 ID，为空时停止全部
+	RedisChannelStopPattern  = "generation:stop:*"    // 全部会话停止信号频道的订阅模式
+	RedisKeyContextSummary   = "summary:%d:%d-%d"     // 截断上下文时对某段消息生成的摘要
+	RedisKeyMemory           = "memory:%d"            // 会话的滚动摘要
+	RedisKeyMemoryLock       = "memory:lock:%d"       // 滚动摘要更新锁
+	RedisKeyQuota            = "quota:%d:%s"          // 用户在某个周期内已用的配额（Hash），周期如 d20240101、m202401
+	RedisKeyRateLimit        = "ratelimit:%s:%s"      // 路由组内某个用户或 IP 的请求时间（Sorted Set）
 )
 
 // GenerateRedisKeyConversation 生成会话的 Redis 键
@@ -13,7 +23,52 @@ func GenerateRedisKeyConversation(conversationID int64) string {
 	return fmt.Sprintf(RedisKeyConversation, conversationID)
 }
 
+// GenerateRedisKeyConversationLock 生成会话写锁的 Redis 键
+func GenerateRedisKeyConversationLock(conversationID int64) string {
+	return fmt.Sprintf(RedisKeyConversationLock, conversationID)
+}
+
 // GenerateRedisKeyJWT 生成 JWT 的 Redis 键
 func GenerateRedisKeyJWT(token string) string {
 	return fmt.Sprintf(RedisKeyJWT, token)
 }
+
+// GenerateRedisKeyGeneration 生成单次生成事件流的 Redis 键
+func GenerateRedisKeyGeneration(conversationID int64, generationID string) string {
+	return fmt.Sprintf(RedisKeyGeneration, conversationID, generationID)
+}
+
+// GenerateRedisChannelStop 生成停止信号的 Pub/Sub 频道名
+func GenerateRedisChannelStop(conversationID int64) string {
+	return fmt.Sprintf(RedisChannelStop, conversationID)
+}
+
+// GenerateRedisKeyContextSummary 生成上下文摘要的 Redis 键，from、to 为被摘要消息的首尾 ID
+func GenerateRedisKeyContextSummary(conversationID int64, from, to int32) string {
+	return fmt.Sprintf(RedisKeyContextSummary, conversationID, from, to)
+}
+
+// GenerateRedisKeyMemory 生成滚动摘要的 Redis 键
+func GenerateRedisKeyMemory(conversationID int64) string {
+	return fmt.Sprintf(RedisKeyMemory, conversationID)
+}
+
+// GenerateRedisKeyMemoryLock 生成滚动摘要更新锁的 Redis 键
+func GenerateRedisKeyMemoryLock(conversationID int64) string {
+	return fmt.Sprintf(RedisKeyMemoryLock, conversationID)
+}
+
+// GenerateRedisKeyLatestGeneration 生成会话最近一次生成 ID 的 Redis 键
+func GenerateRedisKeyLatestGeneration(conversationID int64) string {
+	return fmt.Sprintf(RedisKeyLatestGeneration, conversationID)
+}
+
+// GenerateRedisKeyQuota 生成配额计数的 Redis 键
+func GenerateRedisKeyQuota(userID int64, period string) string {
+	return fmt.Sprintf(RedisKeyQuota, userID, period)
+}
+
+// GenerateRedisKeyRateLimit 生成限流计数的 Redis 键，subject 如 user:1、ip:127.0.0.1
+func GenerateRedisKeyRateLimit(group, subject string) string {
+	return fmt.Sprintf(RedisKeyRateLimit, group, subject)
+}
@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// refreshLockScript 锁仍由 token 持有时延长过期时间，返回 1；已过期或被他人持有时返回 0
+var refreshLockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// releaseLockScript 锁仍由 token 持有时删除，避免误删过期后被他人获取的锁
+var releaseLockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// AcquireConversationLock 以 token 获取会话写锁，已被占用时返回 false
+func AcquireConversationLock(conversationID int64, token string, ttl time.Duration) (bool, error) {
+	ok, err := redisClient.SetNX(ctx, GenerateRedisKeyConversationLock(conversationID), token, ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire conversation lock: %w", err)
+	}
+	return ok, nil
+}
+
+// ConversationLocked 判断会话写锁是否被持有
+func ConversationLocked(conversationID int64) (bool, error) {
+	n, err := redisClient.Exists(ctx, GenerateRedisKeyConversationLock(conversationID)).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to check conversation lock: %w", err)
+	}
+	return n > 0, nil
+}
+
+// RefreshConversationLock 延长 token 持有的会话写锁，锁已丢失时返回 false
+func RefreshConversationLock(conversationID int64, token string, ttl time.Duration) (bool, error) {
+	key := GenerateRedisKeyConversationLock(conversationID)
+	refreshed, err := refreshLockScript.Run(ctx, redisClient, []string{key}, token, ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, fmt.Errorf("failed to refresh conversation lock: %w", err)
+	}
+	return refreshed == 1, nil
+}
+
+// ReleaseConversationLock 释放 token 持有的会话写锁
+func ReleaseConversationLock(conversationID int64, token string) error {
+	key := GenerateRedisKeyConversationLock(conversationID)
+	if err := releaseLockScript.Run(ctx, redisClient, []string{key}, token).Err(); err != nil {
+		return fmt.Errorf("failed to release conversation lock: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// QuotaWindow 一个配额周期的计数键、限额与过期时间。CostMicros 以百万分之一美元计，便于原子累加
+type QuotaWindow struct {
+	Period     string // 周期标识，如 d20240101、m202401
+	Tokens     int64
+	Requests   int64
+	CostMicros int64
+	TTL        time.Duration
+}
+
+// quotaFields 配额计数的 Hash 字段，顺序与 admitScript 的返回值对应
+var quotaFields = []string{"tokens", "requests", "cost"}
+
+// admitScript 检查各周期的已用额度，全部未达到限额时将请求数加一。
+// 返回 {0} 表示放行，否则返回 {周期序号, 字段序号, 已用值}（均从 1 开始）
+var admitScript = redis.NewScript(`
+for i = 1, #KEYS do
+	local used = redis.call('HMGET', KEYS[i], 'tokens', 'requests', 'cost')
+	for j = 1, 3 do
+		local limit = tonumber(ARGV[(i - 1) * 4 + j])
+		local value = tonumber(used[j]) or 0
+		if limit > 0 and value >= limit then
+			return {i, j, value}
+		end
+	end
+end
+for i = 1, #KEYS do
+	redis.call('HINCRBY', KEYS[i], 'requests', 1)
+	redis.call('EXPIRE', KEYS[i], ARGV[i * 4])
+end
+return {0}
+`)
+
+// QuotaRejection 被拒绝的周期序号、字段与已用值
+type QuotaRejection struct {
+	Window int    // windows 中的下标
+	Field  string // tokens | requests | cost
+	Used   int64
+}
+
+// AdmitQuotaRequest 原子地检查配额并记一次请求，超出配额时返回拒绝详情
+func AdmitQuotaRequest(userID int64, windows []QuotaWindow) (*QuotaRejection, error) {
+	keys := make([]string, 0, len(windows))
+	args := make([]interface{}, 0, len(windows)*4)
+	for _, window := range windows {
+		keys = append(keys, GenerateRedisKeyQuota(userID, window.Period))
+		args = append(args, window.Tokens, window.Requests, window.CostMicros, int64(window.TTL.Seconds()))
+	}
+
+	result, err := admitScript.Run(ctx, redisClient, keys, args...).Int64Slice()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check quota: %w", err)
+	}
+	if result[0] == 0 {
+		return nil, nil
+	}
+	return &QuotaRejection{Window: int(result[0]) - 1, Field: quotaFields[result[1]-1], Used: result[2]}, nil
+}
+
+// RefundQuotaRequest 退回 AdmitQuotaRequest 计入的一次请求
+func RefundQuotaRequest(userID int64, windows []QuotaWindow) error {
+	pipe := redisClient.TxPipeline()
+	for _, window := range windows {
+		pipe.HIncrBy(ctx, GenerateRedisKeyQuota(userID, window.Period), "requests", -1)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to refund quota request: %w", err)
+	}
+	return nil
+}
+
+// AddQuotaUsage 累加各周期已用的 token 与费用
+func AddQuotaUsage(userID int64, windows []QuotaWindow, tokens, costMicros int64) error {
+	pipe := redisClient.TxPipeline()
+	for _, window := range windows {
+		key := GenerateRedisKeyQuota(userID, window.Period)
+		pipe.HIncrBy(ctx, key, "tokens", tokens)
+		pipe.HIncrBy(ctx, key, "cost", costMicros)
+		pipe.Expire(ctx, key, window.TTL)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to add quota usage: %w", err)
+	}
+	return nil
+}
+
+// GetQuotaUsage 获取一个周期已用的 token 数、请求数与费用（百万分之一美元）
+func GetQuotaUsage(userID int64, period string) (tokens, requests, costMicros int64, err error) {
+	values, err := redisClient.HMGet(ctx, GenerateRedisKeyQuota(userID, period), quotaFields...).Result()
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to get quota usage: %w", err)
+	}
+	counts := make([]int64, len(values))
+	for i, value := range values {
+		if s, ok := value.(string); ok {
+			fmt.Sscan(s, &counts[i])
+		}
+	}
+	return counts[0], counts[1], counts[2], nil
+}
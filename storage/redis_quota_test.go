@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRefundQuotaRequest(t *testing.T) {
+	useMiniredis(t)
+	const userID = 1
+	windows := []QuotaWindow{
+		{Period: "d20240101", Requests: 2, TTL: time.Hour},
+		{Period: "m202401", TTL: time.Hour},
+	}
+	requests := func(period string) int64 {
+		t.Helper()
+		_, requests, _, err := GetQuotaUsage(userID, period)
+		if err != nil {
+			t.Fatalf("get usage: %v", err)
+		}
+		return requests
+	}
+
+	for i := 0; i < 2; i++ {
+		if rejection, err := AdmitQuotaRequest(userID, windows); err != nil || rejection != nil {
+			t.Fatalf("admit #%d = %+v, %v", i+1, rejection, err)
+		}
+	}
+	if rejection, _ := AdmitQuotaRequest(userID, windows); rejection == nil || rejection.Field != "requests" {
+		t.Fatalf("admit over limit = %+v, want requests rejection", rejection)
+	}
+
+	// 退回后各周期的请求数都减一，可以再放行一次
+	if err := RefundQuotaRequest(userID, windows); err != nil {
+		t.Fatalf("refund: %v", err)
+	}
+	if got := requests("d20240101"); got != 1 {
+		t.Errorf("daily requests = %d, want 1", got)
+	}
+	if got := requests("m202401"); got != 1 {
+		t.Errorf("monthly requests = %d, want 1", got)
+	}
+	if rejection, err := AdmitQuotaRequest(userID, windows); err != nil || rejection != nil {
+		t.Errorf("admit after refund = %+v, %v", rejection, err)
+	}
+}
@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// slidingWindowScript 滑动窗口限流：清除窗口外的请求，未达到上限时记录本次请求。
+// 返回 {是否放行, 窗口内请求数, 最早一次请求离开窗口的剩余毫秒数}
+var slidingWindowScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+local count = redis.call('ZCARD', KEYS[1])
+local allowed = 0
+if count < tonumber(ARGV[3]) then
+	redis.call('ZADD', KEYS[1], now, ARGV[4])
+	count = count + 1
+	allowed = 1
+end
+redis.call('PEXPIRE', KEYS[1], window)
+local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+local reset = 0
+if oldest[2] then
+	reset = tonumber(oldest[2]) + window - now
+end
+return {allowed, count, reset}
+`)
+
+// RateLimitResult 一次限流检查的结果
+type RateLimitResult struct {
+	Allowed bool
+	Count   int           // 窗口内的请求数（含本次放行的请求）
+	Reset   time.Duration // 窗口内最早一次请求离开窗口的剩余时间，即下一个名额释放的时间
+}
+
+// AllowRequest 在滑动窗口内检查并记录一次请求
+func AllowRequest(group, subject string, limit int, window time.Duration) (*RateLimitResult, error) {
+	now := time.Now().UnixMilli()
+	member := fmt.Sprintf("%d-%d", now, rand.Int63())
+	key := GenerateRedisKeyRateLimit(group, subject)
+
+	result, err := slidingWindowScript.Run(ctx, redisClient, []string{key}, now, window.Milliseconds(), limit, member).Int64Slice()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check rate limit: %w", err)
+	}
+	return &RateLimitResult{
+		Allowed: result[0] == 1,
+		Count:   int(result[1]),
+		Reset:   time.Duration(result[2]) * time.Millisecond,
+	}, nil
+}
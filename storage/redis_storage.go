@@ -32,17 +32,49 @@ func InitializeRedis() error {
 	return nil
 }
 
-// SaveConversationToRedis 保存完整会话到 Redis
-func SaveConversationToRedis(conversation *models.Conversation) error {
-	conversationKey := GenerateRedisKeyConversation(conversation.ID)
+// saveConversationScript 会话写锁仍由 ARGV[1] 持有时写入会话，返回 1；锁已丢失时不写入，返回 0
+var saveConversationScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	redis.call('SET', KEYS[2], ARGV[2])
+	return 1
+end
+return 0
+`)
+
+// SaveConversationToRedis 将选中分支合并进消息树后保存完整会话到 Redis。
+// 只在会话写锁仍由 conversation.LockToken 持有时写入，锁已丢失时返回 false，避免覆盖之后获得锁的请求的修改
+func SaveConversationToRedis(conversation *models.Conversation) (bool, error) {
+	mergeTree(conversation)
+	data, err := json.Marshal(conversation)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal conversation: %v", err)
+	}
+	keys := []string{GenerateRedisKeyConversationLock(conversation.ID), GenerateRedisKeyConversation(conversation.ID)}
+	saved, err := saveConversationScript.Run(ctx, redisClient, keys, conversation.LockToken, data).Int()
+	if err != nil {
+		return false, fmt.Errorf("failed to save conversation to redis: %v", err)
+	}
+	return saved == 1, nil
+}
+
+// CreateConversationInRedis 保存新建的会话。新会话尚未被其他请求访问，不需要持有写锁；会话已存在时返回错误
+func CreateConversationInRedis(conversation *models.Conversation) error {
+	mergeTree(conversation)
 	data, err := json.Marshal(conversation)
 	if err != nil {
 		return fmt.Errorf("failed to marshal conversation: %v", err)
 	}
-	return redisClient.Set(ctx, conversationKey, data, 0).Err()
+	created, err := redisClient.SetNX(ctx, GenerateRedisKeyConversation(conversation.ID), data, 0).Result()
+	if err != nil {
+		return fmt.Errorf("failed to create conversation in redis: %v", err)
+	}
+	if !created {
+		return fmt.Errorf("conversation %d already exists", conversation.ID)
+	}
+	return nil
 }
 
-// GetConversationFromRedis 从 Redis 获取完整会话
+// GetConversationFromRedis 从 Redis 获取完整会话，Messages 为选中的分支
 func GetConversationFromRedis(conversationID int64) (*models.Conversation, error) {
 	conversationKey := GenerateRedisKeyConversation(conversationID)
 	data, err := redisClient.Get(ctx, conversationKey).Result()
@@ -56,6 +88,7 @@ func GetConversationFromRedis(conversationID int64) (*models.Conversation, error
 	if err := json.Unmarshal([]byte(data), &conversation); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal conversation: %v", err)
 	}
+	restoreTree(&conversation)
 	return &conversation, nil
 }
 
@@ -96,3 +129,65 @@ func GetCachedJWT(tokenStr string) (map[string]interface{}, error) {
 
 	return value, nil
 }
+
+// SetContextSummary 缓存上下文摘要
+func SetContextSummary(conversationID int64, from, to int32, summary string, ttl time.Duration) error {
+	key := GenerateRedisKeyContextSummary(conversationID, from, to)
+	return redisClient.Set(ctx, key, summary, ttl).Err()
+}
+
+// GetContextSummary 获取缓存的上下文摘要，不存在时返回空字符串
+func GetContextSummary(conversationID int64, from, to int32) (string, error) {
+	key := GenerateRedisKeyContextSummary(conversationID, from, to)
+	summary, err := redisClient.Get(ctx, key).Result()
+	if err == redis.Nil {
+		return "", nil
+	} else if err != nil {
+		return "", fmt.Errorf("failed to get context summary: %w", err)
+	}
+	return summary, nil
+}
+
+// SaveMemoryToRedis 缓存会话的滚动摘要
+func SaveMemoryToRedis(conversationID int64, memory *models.ConversationMemory) error {
+	data, err := json.Marshal(memory)
+	if err != nil {
+		return fmt.Errorf("failed to marshal memory: %v", err)
+	}
+	return redisClient.Set(ctx, GenerateRedisKeyMemory(conversationID), data, 0).Err()
+}
+
+// GetMemoryFromRedis 获取缓存的滚动摘要，未命中时返回 nil
+func GetMemoryFromRedis(conversationID int64) (*models.ConversationMemory, error) {
+	data, err := redisClient.Get(ctx, GenerateRedisKeyMemory(conversationID)).Result()
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get memory from redis: %w", err)
+	}
+
+	var memory models.ConversationMemory
+	if err := json.Unmarshal([]byte(data), &memory); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal memory: %w", err)
+	}
+	return &memory, nil
+}
+
+// DeleteMemoryFromRedis 删除缓存的滚动摘要
+func DeleteMemoryFromRedis(conversationID int64) error {
+	return redisClient.Del(ctx, GenerateRedisKeyMemory(conversationID)).Err()
+}
+
+// AcquireMemoryLock 获取滚动摘要更新锁，已被占用时返回 false
+func AcquireMemoryLock(conversationID int64, ttl time.Duration) (bool, error) {
+	ok, err := redisClient.SetNX(ctx, GenerateRedisKeyMemoryLock(conversationID), 1, ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire memory lock: %w", err)
+	}
+	return ok, nil
+}
+
+// ReleaseMemoryLock 释放滚动摘要更新锁
+func ReleaseMemoryLock(conversationID int64) error {
+	return redisClient.Del(ctx, GenerateRedisKeyMemoryLock(conversationID)).Err()
+}
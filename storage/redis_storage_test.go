@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/EthanGuo-coder/llm-backend-api/models"
+)
+
+// useMiniredis 将 Redis 客户端指向测试用的内存 Redis
+func useMiniredis(t *testing.T) *miniredis.Miniredis {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	redisClient = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { redisClient.Close() })
+	return mr
+}
+
+func TestSaveConversationFenced(t *testing.T) {
+	const conversationID = 7
+	tests := []struct {
+		name      string
+		lockToken string // 当前持有写锁的令牌，空表示锁不存在
+		saveToken string // 保存时携带的令牌
+		saved     bool
+	}{
+		{"lock held", "a", "a", true},
+		{"stale token", "b", "a", false},
+		{"lock expired", "", "a", false},
+		{"no token", "a", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useMiniredis(t)
+			original := &models.Conversation{ID: conversationID, Title: "original", Messages: []models.Message{{Role: "system"}}}
+			if err := CreateConversationInRedis(original); err != nil {
+				t.Fatalf("create: %v", err)
+			}
+			if tt.lockToken != "" {
+				if _, err := AcquireConversationLock(conversationID, tt.lockToken, time.Minute); err != nil {
+					t.Fatalf("acquire: %v", err)
+				}
+			}
+
+			conversation := &models.Conversation{ID: conversationID, Title: "updated", LockToken: tt.saveToken, Messages: []models.Message{{Role: "system"}}}
+			saved, err := SaveConversationToRedis(conversation)
+			if err != nil {
+				t.Fatalf("save: %v", err)
+			}
+			if saved != tt.saved {
+				t.Errorf("saved = %v, want %v", saved, tt.saved)
+			}
+
+			stored, err := GetConversationFromRedis(conversationID)
+			if err != nil {
+				t.Fatalf("get: %v", err)
+			}
+			want := "original"
+			if tt.saved {
+				want = "updated"
+			}
+			if stored.Title != want {
+				t.Errorf("stored title = %q, want %q", stored.Title, want)
+			}
+		})
+	}
+}
+
+func TestCreateConversationInRedis(t *testing.T) {
+	useMiniredis(t)
+	conversation := &models.Conversation{ID: 1, Title: "first"}
+	if err := CreateConversationInRedis(conversation); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	// 已存在的会话不会被新建覆盖
+	if err := CreateConversationInRedis(&models.Conversation{ID: 1, Title: "second"}); err == nil {
+		t.Error("creating an existing conversation succeeded")
+	}
+	stored, err := GetConversationFromRedis(1)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if stored.Title != "first" {
+		t.Errorf("stored title = %q, want %q", stored.Title, "first")
+	}
+}
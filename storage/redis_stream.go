@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/EthanGuo-coder/llm-backend-api/models"
+)
+
+// AppendGenerationEvent 将事件追加到生成事件流并刷新过期时间，返回条目 ID
+func AppendGenerationEvent(conversationID int64, generationID, event string, data []byte, ttl time.Duration) (string, error) {
+	key := GenerateRedisKeyGeneration(conversationID, generationID)
+
+	pipe := redisClient.TxPipeline()
+	add := pipe.XAdd(ctx, &redis.XAddArgs{
+		Stream: key,
+		Values: map[string]interface{}{"event": event, "data": data},
+	})
+	pipe.Expire(ctx, key, ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return "", fmt.Errorf("failed to append generation event: %w", err)
+	}
+	return add.Val(), nil
+}
+
+// ReadGenerationEvents 读取 afterID 之后的事件，没有新事件时最多阻塞 block
+func ReadGenerationEvents(reqCtx context.Context, conversationID int64, generationID, afterID string, block time.Duration) ([]models.StreamEvent, error) {
+	key := GenerateRedisKeyGeneration(conversationID, generationID)
+	streams, err := redisClient.XRead(reqCtx, &redis.XReadArgs{
+		Streams: []string{key, afterID},
+		Count:   100,
+		Block:   block,
+	}).Result()
+	if err == redis.Nil {
+		return nil, nil // 等待超时，没有新事件
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read generation events: %w", err)
+	}
+
+	var events []models.StreamEvent
+	for _, stream := range streams {
+		for _, message := range stream.Messages {
+			event, _ := message.Values["event"].(string)
+			data, _ := message.Values["data"].(string)
+			events = append(events, models.StreamEvent{ID: message.ID, Event: event, Data: []byte(data)})
+		}
+	}
+	return events, nil
+}
+
+// GenerationExists 判断生成事件流是否存在（未过期）
+func GenerationExists(conversationID int64, generationID string) (bool, error) {
+	key := GenerateRedisKeyGeneration(conversationID, generationID)
+	n, err := redisClient.Exists(ctx, key).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to check generation: %w", err)
+	}
+	return n > 0, nil
+}
+
+// SetLatestGeneration 记录会话最近一次生成的 ID
+func SetLatestGeneration(conversationID int64, generationID string, ttl time.Duration) error {
+	key := GenerateRedisKeyLatestGeneration(conversationID)
+	return redisClient.Set(ctx, key, generationID, ttl).Err()
+}
+
+// GetLatestGeneration 获取会话最近一次生成的 ID，不存在时返回空字符串
+func GetLatestGeneration(conversationID int64) (string, error) {
+	key := GenerateRedisKeyLatestGeneration(conversationID)
+	generationID, err := redisClient.Get(ctx, key).Result()
+	if err == redis.Nil {
+		return "", nil
+	} else if err != nil {
+		return "", fmt.Errorf("failed to get latest generation: %w", err)
+	}
+	return generationID, nil
+}
+
+// StopSignal 停止信号：会话 ID 与要停止的生成 ID，生成 ID 为空时停止会话中全部生成
+type StopSignal struct {
+	ConversationID int64
+	GenerationID   string
+}
+
+// PublishStopSignal 发布停止信号
+func PublishStopSignal(conversationID int64, generationID string) error {
+	if err := redisClient.Publish(ctx, GenerateRedisChannelStop(conversationID), generationID).Err(); err != nil {
+		return fmt.Errorf("failed to publish stop signal: %w", err)
+	}
+	return nil
+}
+
+// SubscribeStopSignals 按模式订阅全部会话的停止信号，reqCtx 取消时取消订阅。
+// 返回前已确认订阅生效，之后发布的信号不会丢失；连接断开时自动重新订阅
+func SubscribeStopSignals(reqCtx context.Context) (<-chan StopSignal, error) {
+	sub := redisClient.PSubscribe(reqCtx, RedisChannelStopPattern)
+	if _, err := sub.Receive(reqCtx); err != nil {
+		sub.Close()
+		return nil, fmt.Errorf("failed to subscribe stop signals: %w", err)
+	}
+	go func() {
+		<-reqCtx.Done()
+		sub.Close()
+	}()
+
+	signals := make(chan StopSignal)
+	go func() {
+		defer close(signals)
+		for message := range sub.Channel() {
+			var conversationID int64
+			if _, err := fmt.Sscanf(message.Channel, RedisChannelStop, &conversationID); err != nil {
+				continue
+			}
+			signals <- StopSignal{ConversationID: conversationID, GenerationID: message.Payload}
+		}
+	}()
+	return signals, nil
+}
@@ -15,19 +15,161 @@ const (
 			title TEXT NOT NULL,
 			user_id INTEGER NOT NULL,
 			create_time INTEGER NOT NULL, -- 添加创建时间字段，存储 Unix 时间戳
+			forked_from TEXT, -- 分叉来源会话，非分叉会话为 NULL
+			forked_from_message INTEGER, -- 复制到的源会话消息
 			FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
 		);`
 
+	CreateTableFiles = `
+		CREATE TABLE IF NOT EXISTS files (
+			id TEXT PRIMARY KEY,
+			user_id INTEGER NOT NULL,
+			filename TEXT NOT NULL,
+			mime_type TEXT NOT NULL,
+			size INTEGER NOT NULL,
+			has_thumbnail INTEGER NOT NULL DEFAULT 0,
+			create_time INTEGER NOT NULL,
+			FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+		);`
+
+	CreateTableConversationMemories = `
+		CREATE TABLE IF NOT EXISTS conversation_memories (
+			conversation_id TEXT PRIMARY KEY,
+			summary TEXT NOT NULL,
+			through_message_id INTEGER NOT NULL,
+			model TEXT NOT NULL,
+			update_time INTEGER NOT NULL
+		);`
+
+	UpsertConversationMemory = `
+        INSERT INTO conversation_memories (conversation_id, summary, through_message_id, model, update_time)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(conversation_id) DO UPDATE SET
+			summary = excluded.summary,
+			through_message_id = excluded.through_message_id,
+			model = excluded.model,
+			update_time = excluded.update_time;`
+
+	FetchConversationMemory = `
+        SELECT summary, through_message_id, model, update_time
+		FROM conversation_memories
+		WHERE conversation_id = ?;`
+
+	DeleteConversationMemory = `
+        DELETE FROM conversation_memories
+        WHERE conversation_id = ?;`
+
+	CreateTableUsageRecords = `
+		CREATE TABLE IF NOT EXISTS usage_records (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			conversation_id INTEGER NOT NULL,
+			message_id INTEGER NOT NULL,
+			provider TEXT NOT NULL DEFAULT '',
+			model TEXT NOT NULL,
+			prompt_tokens INTEGER NOT NULL,
+			completion_tokens INTEGER NOT NULL,
+			total_tokens INTEGER NOT NULL,
+			cost REAL NOT NULL DEFAULT 0, -- 美元
+			latency_ms INTEGER NOT NULL,
+			ttft_ms INTEGER NOT NULL,
+			create_time INTEGER NOT NULL,
+			kind TEXT NOT NULL DEFAULT 'reply' -- reply | summary
+		);`
+
+	CreateIndexUsageRecordsTime = `
+		CREATE INDEX IF NOT EXISTS idx_usage_records_time ON usage_records (create_time);`
+
+	CreateIndexUsageRecordsUser = `
+		CREATE INDEX IF NOT EXISTS idx_usage_records_user ON usage_records (user_id, conversation_id);`
+
+	InsertUsageRecord = `
+        INSERT INTO usage_records (user_id, conversation_id, message_id, provider, model, prompt_tokens, completion_tokens, total_tokens, cost, latency_ms, ttft_ms, create_time, kind)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`
+
+	// usageColumns 汇总列，请求数只统计回复（摘要调用只计入 token 与费用），耗时为 0 表示未记录，不参与平均
+	usageColumns = `
+		COUNT(CASE WHEN kind = 'reply' THEN 1 END), COALESCE(SUM(prompt_tokens), 0), COALESCE(SUM(completion_tokens), 0), COALESCE(SUM(total_tokens), 0),
+		COALESCE(SUM(cost), 0), COALESCE(CAST(AVG(NULLIF(latency_ms, 0)) AS INTEGER), 0), COALESCE(CAST(AVG(NULLIF(ttft_ms, 0)) AS INTEGER), 0)`
+
+	FetchUserUsage = `
+        SELECT` + usageColumns + `
+		FROM usage_records
+		WHERE user_id = ?;`
+
+	FetchUserUsageByConversation = `
+        SELECT conversation_id,` + usageColumns + `
+		FROM usage_records
+		WHERE user_id = ?
+		GROUP BY conversation_id
+		ORDER BY conversation_id DESC;`
+
+	FetchConversationUsage = `
+        SELECT` + usageColumns + `
+		FROM usage_records
+		WHERE user_id = ? AND conversation_id = ?;`
+
+	CreateTableUserQuotas = `
+		CREATE TABLE IF NOT EXISTS user_quotas (
+			user_id INTEGER PRIMARY KEY,
+			daily_tokens INTEGER NOT NULL,
+			daily_requests INTEGER NOT NULL,
+			daily_cost REAL NOT NULL,
+			monthly_tokens INTEGER NOT NULL,
+			monthly_requests INTEGER NOT NULL,
+			monthly_cost REAL NOT NULL,
+			update_time INTEGER NOT NULL,
+			FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+		);`
+
+	UpsertUserQuota = `
+        INSERT INTO user_quotas (user_id, daily_tokens, daily_requests, daily_cost, monthly_tokens, monthly_requests, monthly_cost, update_time)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(user_id) DO UPDATE SET
+			daily_tokens = excluded.daily_tokens,
+			daily_requests = excluded.daily_requests,
+			daily_cost = excluded.daily_cost,
+			monthly_tokens = excluded.monthly_tokens,
+			monthly_requests = excluded.monthly_requests,
+			monthly_cost = excluded.monthly_cost,
+			update_time = excluded.update_time;`
+
+	FetchUserQuota = `
+        SELECT daily_tokens, daily_requests, daily_cost, monthly_tokens, monthly_requests, monthly_cost
+		FROM user_quotas
+		WHERE user_id = ?;`
+
+	DeleteUserQuota = `
+        DELETE FROM user_quotas
+        WHERE user_id = ?;`
+
+	CountUser = `
+        SELECT COUNT(*) FROM users WHERE id = ?;`
+
+	InsertFile = `
+        INSERT INTO files (id, user_id, filename, mime_type, size, has_thumbnail, create_time)
+		VALUES (?, ?, ?, ?, ?, ?, ?);`
+
+	FetchFile = `
+        SELECT id, user_id, filename, mime_type, size, has_thumbnail, create_time
+		FROM files
+		WHERE id = ?;`
+
 	InsertConversation = `
-        INSERT INTO conversations (id, title, user_id, create_time) 
-		VALUES (?, ?, ?, ?);`
+        INSERT INTO conversations (id, title, user_id, create_time, forked_from, forked_from_message) 
+		VALUES (?, ?, ?, ?, ?, ?);`
 
 	DeleteConversation = `
         DELETE FROM conversations 
         WHERE id = ? AND user_id = ?;`
 
+	FetchConversationOwner = `
+        SELECT user_id
+		FROM conversations
+		WHERE id = ?;`
+
 	FetchConversations = `
-        SELECT id, title, create_time
+        SELECT id, title, create_time, forked_from, forked_from_message
 		FROM conversations 
 		WHERE user_id = ? 
 		ORDER BY ROWID DESC;`
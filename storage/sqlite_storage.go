@@ -68,6 +68,12 @@ func createTables(db *sql.DB) error {
 	tableSchemas := []string{
 		CreateTableUsers,
 		CreateTableConversations,
+		CreateTableFiles,
+		CreateTableConversationMemories,
+		CreateTableUsageRecords,
+		CreateIndexUsageRecordsUser,
+		CreateIndexUsageRecordsTime,
+		CreateTableUserQuotas,
 	}
 
 	for _, schema := range tableSchemas {
@@ -75,15 +81,76 @@ func createTables(db *sql.DB) error {
 			return fmt.Errorf("failed to execute schema: %w", err)
 		}
 	}
+	return addColumns(db)
+}
+
+// tableColumn 建表语句中后来新增的列
+type tableColumn struct {
+	table      string
+	name       string
+	definition string
+}
+
+// addedColumns 旧版本创建的表缺少这些列，启动时补齐
+var addedColumns = []tableColumn{
+	{"conversations", "forked_from", "TEXT"},
+	{"conversations", "forked_from_message", "INTEGER"},
+}
+
+// addColumns 为已存在的表补齐新增的列
+func addColumns(db *sql.DB) error {
+	for _, column := range addedColumns {
+		exists, err := columnExists(db, column.table, column.name)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		query := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", column.table, column.name, column.definition)
+		if _, err := db.Exec(query); err != nil {
+			return fmt.Errorf("failed to add column %s.%s: %w", column.table, column.name, err)
+		}
+	}
 	return nil
 }
 
+// columnExists 判断表中是否存在指定列
+func columnExists(db *sql.DB, table, column string) (bool, error) {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return false, fmt.Errorf("failed to inspect table %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid          int
+			name, ctype  string
+			notNull, pk  int
+			defaultValue sql.NullString
+		)
+		if err := rows.Scan(&cid, &name, &ctype, &notNull, &defaultValue, &pk); err != nil {
+			return false, fmt.Errorf("failed to inspect table %s: %w", table, err)
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
 // SaveConversationToDB 将会话保存到数据库
 func SaveConversationToDB(userID int64, conversation *models.Conversation) error {
 	db := GetDB()
 	// 插入会话记录到数据库
 	query := InsertConversation
-	_, err := db.Exec(query, conversation.ID, conversation.Title, userID, conversation.CreatedTime) // 添加 CreatedTime
+	// 非分叉会话的来源列为 NULL
+	var forkedFrom, forkedFromMessage interface{}
+	if conversation.ForkedFrom != nil {
+		forkedFrom, forkedFromMessage = conversation.ForkedFrom.ConversationID, conversation.ForkedFrom.MessageID
+	}
+	_, err := db.Exec(query, conversation.ID, conversation.Title, userID, conversation.CreatedTime, forkedFrom, forkedFromMessage) // 添加 CreatedTime
 	if err != nil {
 		return errors.New("failed to insert conversation: " + err.Error())
 	}
@@ -103,6 +170,20 @@ func DeleteConversationFromDB(userID int64, conversationID int64) error {
 	return nil
 }
 
+// GetConversationOwner 查询会话所属的用户 ID，会话不存在时返回 0
+func GetConversationOwner(conversationID int64) (int64, error) {
+	db := GetDB()
+	var userID int64
+	err := db.QueryRow(FetchConversationOwner, conversationID).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, errors.New("failed to fetch conversation owner: " + err.Error())
+	}
+	return userID, nil
+}
+
 // FetchConversationsByUserID 从数据库中获取指定用户的所有会话
 func FetchConversationsByUserID(userID int64) ([]*models.Conversation, error) {
 	db := GetDB()
@@ -116,9 +197,16 @@ func FetchConversationsByUserID(userID int64) ([]*models.Conversation, error) {
 	var conversations []*models.Conversation
 	for rows.Next() {
 		var conversation models.Conversation
-		if err := rows.Scan(&conversation.ID, &conversation.Title, &conversation.CreatedTime); err != nil {
+		var forkedFrom, forkedFromMessage sql.NullInt64
+		if err := rows.Scan(&conversation.ID, &conversation.Title, &conversation.CreatedTime, &forkedFrom, &forkedFromMessage); err != nil {
 			return nil, errors.New("failed to scan conversation: " + err.Error())
 		}
+		if forkedFrom.Valid {
+			conversation.ForkedFrom = &models.ForkSource{
+				ConversationID: forkedFrom.Int64,
+				MessageID:      int32(forkedFromMessage.Int64),
+			}
+		}
 		conversations = append(conversations, &conversation)
 	}
 	if err := rows.Err(); err != nil {
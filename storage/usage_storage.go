@@ -0,0 +1,169 @@
+package storage
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/EthanGuo-coder/llm-backend-api/constant"
+
+	"github.com/EthanGuo-coder/llm-backend-api/models"
+)
+
+// SaveUsageRecord 保存单次回复或摘要调用的用量记录
+func SaveUsageRecord(record *models.UsageRecord) error {
+	db := GetDB()
+	_, err := db.Exec(InsertUsageRecord,
+		record.UserID, record.ConversationID, record.MessageID, record.Provider, record.Model,
+		record.PromptTokens, record.CompletionTokens, record.TotalTokens, record.Cost,
+		record.LatencyMs, record.TTFTMs, record.CreatedTime, record.Kind,
+	)
+	if err != nil {
+		return errors.New("failed to insert usage record: " + err.Error())
+	}
+	return nil
+}
+
+// GetUserUsageFromDB 汇总用户的全部用量
+func GetUserUsageFromDB(userID int64) (*models.UsageSummary, error) {
+	db := GetDB()
+	var summary models.UsageSummary
+	if err := db.QueryRow(FetchUserUsage, userID).Scan(usageFields(&summary)...); err != nil {
+		return nil, errors.New("failed to fetch usage: " + err.Error())
+	}
+	return &summary, nil
+}
+
+// GetUserUsageByConversationFromDB 按会话汇总用户的用量
+func GetUserUsageByConversationFromDB(userID int64) ([]models.ConversationUsage, error) {
+	db := GetDB()
+	rows, err := db.Query(FetchUserUsageByConversation, userID)
+	if err != nil {
+		return nil, errors.New("failed to fetch usage: " + err.Error())
+	}
+	defer rows.Close()
+
+	usages := []models.ConversationUsage{}
+	for rows.Next() {
+		var usage models.ConversationUsage
+		fields := append([]interface{}{&usage.ConversationID}, usageFields(&usage.UsageSummary)...)
+		if err := rows.Scan(fields...); err != nil {
+			return nil, errors.New("failed to scan usage: " + err.Error())
+		}
+		usages = append(usages, usage)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.New("row iteration error: " + err.Error())
+	}
+	return usages, nil
+}
+
+// GetConversationUsageFromDB 汇总用户在单个会话中的用量
+func GetConversationUsageFromDB(userID, conversationID int64) (*models.UsageSummary, error) {
+	db := GetDB()
+	var summary models.UsageSummary
+	if err := db.QueryRow(FetchConversationUsage, userID, conversationID).Scan(usageFields(&summary)...); err != nil {
+		return nil, errors.New("failed to fetch usage: " + err.Error())
+	}
+	return &summary, nil
+}
+
+// usageFields 返回与 usageColumns 顺序一致的扫描目标
+func usageFields(summary *models.UsageSummary) []interface{} {
+	return []interface{}{
+		&summary.Requests, &summary.PromptTokens, &summary.CompletionTokens, &summary.TotalTokens,
+		&summary.Cost, &summary.AvgLatencyMs, &summary.AvgTTFTMs,
+	}
+}
+
+// reportDimensions 报表分组维度对应的列与扫描目标，顺序即输出顺序
+var reportDimensions = []struct {
+	name    string
+	columns []string
+	fields  func(row *models.ReportRow) []interface{}
+}{
+	{
+		name:    constant.ReportByDay,
+		columns: []string{"strftime('%Y-%m-%d', r.create_time, 'unixepoch')"},
+		fields:  func(row *models.ReportRow) []interface{} { return []interface{}{&row.Day} },
+	},
+	{
+		name:    constant.ReportByUser,
+		columns: []string{"r.user_id", "COALESCE(u.username, '')"},
+		fields:  func(row *models.ReportRow) []interface{} { return []interface{}{&row.UserID, &row.Username} },
+	},
+	{
+		name:    constant.ReportByModel,
+		columns: []string{"r.provider", "r.model"},
+		fields:  func(row *models.ReportRow) []interface{} { return []interface{}{&row.Provider, &row.Model} },
+	},
+	{
+		name:    constant.ReportByKind,
+		columns: []string{"r.kind"},
+		fields:  func(row *models.ReportRow) []interface{} { return []interface{}{&row.Kind} },
+	},
+}
+
+// GetUsageReportFromDB 按维度汇总时间范围内的用量与费用
+func GetUsageReportFromDB(query *models.ReportQuery) ([]models.ReportRow, error) {
+	var columns []string
+	for _, dimension := range reportDimensions {
+		if slices.Contains(query.GroupBy, dimension.name) {
+			columns = append(columns, dimension.columns...)
+		}
+	}
+
+	sqlQuery := "SELECT "
+	for _, column := range columns {
+		sqlQuery += column + ", "
+	}
+	// 与用量汇总一致，请求数只统计回复
+	sqlQuery += `COUNT(CASE WHEN r.kind = 'reply' THEN 1 END), COALESCE(SUM(r.prompt_tokens), 0), COALESCE(SUM(r.completion_tokens), 0),
+		COALESCE(SUM(r.total_tokens), 0), COALESCE(SUM(r.cost), 0)
+		FROM usage_records r LEFT JOIN users u ON u.id = r.user_id
+		WHERE r.create_time >= ? AND r.create_time < ?`
+	args := []interface{}{query.From, query.To}
+	if query.UserID != 0 {
+		sqlQuery += " AND r.user_id = ?"
+		args = append(args, query.UserID)
+	}
+	if len(columns) > 0 {
+		// 按列序号分组与排序
+		positions := make([]string, len(columns))
+		for i := range columns {
+			positions[i] = strconv.Itoa(i + 1)
+		}
+		sqlQuery += " GROUP BY " + strings.Join(positions, ", ") + " ORDER BY " + strings.Join(positions, ", ")
+	}
+
+	db := GetDB()
+	rows, err := db.Query(sqlQuery, args...)
+	if err != nil {
+		return nil, errors.New("failed to fetch usage report: " + err.Error())
+	}
+	defer rows.Close()
+
+	report := []models.ReportRow{}
+	for rows.Next() {
+		var row models.ReportRow
+		var fields []interface{}
+		for _, dimension := range reportDimensions {
+			if slices.Contains(query.GroupBy, dimension.name) {
+				fields = append(fields, dimension.fields(&row)...)
+			}
+		}
+		fields = append(fields, &row.Requests, &row.PromptTokens, &row.CompletionTokens, &row.TotalTokens, &row.Cost)
+		if err := rows.Scan(fields...); err != nil {
+			return nil, errors.New("failed to scan usage report: " + err.Error())
+		}
+		// 不分组时即使范围内没有记录也会返回一行零值
+		if row.Requests > 0 {
+			report = append(report, row)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.New("row iteration error: " + err.Error())
+	}
+	return report, nil
+}
@@ -0,0 +1,196 @@
+package tokenizer
+
+import (
+	"log"
+	"math"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/pkoukk/tiktoken-go"
+	tiktokenloader "github.com/pkoukk/tiktoken-go-loader"
+
+	"github.com/EthanGuo-coder/llm-backend-api/models"
+)
+
+func init() {
+	// 使用内嵌的 BPE 词表，不在运行时下载
+	tiktoken.SetBpeLoader(tiktokenloader.NewOfflineLoader())
+}
+
+// Estimator 按模型族计算 token 数，用于在发送前判断请求是否超出上下文窗口。
+// 有公开 BPE 词表的模型族（OpenAI）用 tiktoken 精确分词；其余模型族先按 BPE 分词器的预切分规则
+// 把文本切成单词、数字、中日韩字符与标点，再按平均压缩率折算，结果是估计值。
+type Estimator struct {
+	Family          string
+	encoding        string  // tiktoken 编码名，为空时按启发式估算
+	charsPerToken   float64 // 单词字符每 token 的平均字符数
+	digitsPerToken  float64 // 数字每 token 的平均位数
+	tokensPerCJK    float64 // 每个中日韩字符的 token 数
+	messageOverhead int     // 每条消息的角色与格式开销
+	imageTokens     int     // 每张图片的 token 数
+	fileTokens      int     // 每个文件片段的 token 数
+
+	once sync.Once
+	bpe  *tiktoken.Tiktoken // 首次使用时加载，加载失败时为空并退回启发式估算
+}
+
+// 各模型族的估算参数
+var (
+	openAIEstimator       = &Estimator{Family: "openai", encoding: tiktoken.MODEL_O200K_BASE, charsPerToken: 4, digitsPerToken: 3, tokensPerCJK: 0.8, messageOverhead: 4, imageTokens: 765, fileTokens: 2000}
+	openAILegacyEstimator = &Estimator{Family: "openai-legacy", encoding: tiktoken.MODEL_CL100K_BASE, charsPerToken: 4, digitsPerToken: 3, tokensPerCJK: 0.8, messageOverhead: 4, imageTokens: 765, fileTokens: 2000}
+	anthropicEstimator    = &Estimator{Family: "anthropic", charsPerToken: 3.5, digitsPerToken: 3, tokensPerCJK: 1.2, messageOverhead: 5, imageTokens: 1600, fileTokens: 2000}
+	glmEstimator          = &Estimator{Family: "glm", charsPerToken: 4, digitsPerToken: 3, tokensPerCJK: 0.6, messageOverhead: 4, imageTokens: 1000, fileTokens: 2000}
+	defaultEstimator      = &Estimator{Family: "default", charsPerToken: 3.5, digitsPerToken: 2, tokensPerCJK: 1, messageOverhead: 4, imageTokens: 800, fileTokens: 2000}
+)
+
+// ForModel 根据模型名选择估算器
+func ForModel(model string) *Estimator {
+	name := strings.ToLower(model)
+	switch {
+	// gpt-4o、gpt-4.1 等较新的模型使用 o200k_base，gpt-4 与 gpt-3.5 使用 cl100k_base
+	case name == "gpt-4", strings.HasPrefix(name, "gpt-4-"), strings.HasPrefix(name, "gpt-3.5"):
+		return openAILegacyEstimator
+	case strings.HasPrefix(name, "gpt"), strings.HasPrefix(name, "o1"), strings.HasPrefix(name, "o3"), strings.HasPrefix(name, "o4"):
+		return openAIEstimator
+	case strings.HasPrefix(name, "claude"):
+		return anthropicEstimator
+	case strings.HasPrefix(name, "glm"):
+		return glmEstimator
+	}
+	return defaultEstimator
+}
+
+// encoder 返回模型族的 BPE 分词器，没有公开词表或加载失败时返回 nil
+func (e *Estimator) encoder() *tiktoken.Tiktoken {
+	if e.encoding == "" {
+		return nil
+	}
+	e.once.Do(func() {
+		bpe, err := tiktoken.GetEncoding(e.encoding)
+		if err != nil {
+			log.Printf("tokenizer: failed to load %s, falling back to estimation: %v", e.encoding, err)
+			return
+		}
+		e.bpe = bpe
+	})
+	return e.bpe
+}
+
+// Count 计算文本的 token 数
+func (e *Estimator) Count(text string) int {
+	if bpe := e.encoder(); bpe != nil {
+		return len(bpe.EncodeOrdinary(text))
+	}
+	return e.estimate(text)
+}
+
+// Truncate 截取文本开头不超过 maxTokens 个 token 的部分
+func (e *Estimator) Truncate(text string, maxTokens int) string {
+	if maxTokens <= 0 {
+		return ""
+	}
+	if bpe := e.encoder(); bpe != nil {
+		tokens := bpe.EncodeOrdinary(text)
+		if len(tokens) <= maxTokens {
+			return text
+		}
+		// 多字节字符可能被拆在两个 token 中，去掉截断处不完整的字符
+		return strings.ToValidUTF8(bpe.Decode(tokens[:maxTokens]), "")
+	}
+
+	runes := []rune(text)
+	for count := e.estimate(string(runes)); count > maxTokens; count = e.estimate(string(runes)) {
+		runes = runes[:min(len(runes)-1, len(runes)*maxTokens/count)]
+	}
+	return string(runes)
+}
+
+// estimate 按预切分结果与平均压缩率估算文本的 token 数
+func (e *Estimator) estimate(text string) int {
+	var tokens float64
+	var word, digits int
+
+	flush := func() {
+		if word > 0 {
+			tokens += math.Ceil(float64(word) / e.charsPerToken)
+			word = 0
+		}
+		if digits > 0 {
+			tokens += math.Ceil(float64(digits) / e.digitsPerToken)
+			digits = 0
+		}
+	}
+
+	for _, r := range text {
+		switch {
+		case isCJK(r):
+			flush()
+			tokens += e.tokensPerCJK
+		case unicode.IsDigit(r):
+			if word > 0 {
+				flush()
+			}
+			digits++
+		case unicode.IsLetter(r):
+			if digits > 0 {
+				flush()
+			}
+			word++
+		case unicode.IsSpace(r):
+			// 空白通常并入下一个单词的 token
+			flush()
+		default:
+			flush()
+			tokens++
+		}
+	}
+	flush()
+	return int(math.Ceil(tokens))
+}
+
+// CountMessage 估算单条消息的 token 数，包括工具调用与多模态片段
+func (e *Estimator) CountMessage(message models.Message) int {
+	tokens := e.messageOverhead
+	if len(message.Parts) > 0 {
+		for _, part := range message.Parts {
+			switch part.Type {
+			case models.PartText:
+				tokens += e.Count(part.Text)
+			case models.PartImageURL:
+				tokens += e.imageTokens
+			case models.PartFile:
+				tokens += e.fileTokens
+			}
+		}
+	} else {
+		tokens += e.Count(message.Content)
+	}
+	for _, call := range message.ToolCalls {
+		tokens += e.Count(call.Name) + e.Count(call.Arguments) + e.messageOverhead
+	}
+	return tokens
+}
+
+// CountMessages 估算多条消息的 token 数
+func (e *Estimator) CountMessages(messages []models.Message) int {
+	var tokens int
+	for _, message := range messages {
+		tokens += e.CountMessage(message)
+	}
+	return tokens
+}
+
+// CountTools 估算工具声明的 token 数
+func (e *Estimator) CountTools(definitions []models.ToolDefinition) int {
+	var tokens int
+	for _, definition := range definitions {
+		tokens += e.Count(definition.Name) + e.Count(definition.Description) + e.Count(string(definition.Parameters))
+	}
+	return tokens
+}
+
+// isCJK 判断是否为中日韩字符
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r)
+}
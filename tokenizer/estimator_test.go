@@ -0,0 +1,112 @@
+package tokenizer
+
+import (
+	"testing"
+
+	"github.com/EthanGuo-coder/llm-backend-api/models"
+)
+
+func TestForModel(t *testing.T) {
+	tests := []struct {
+		model string
+		want  string
+	}{
+		{"gpt-4o-mini", "openai"},
+		{"gpt-4.1", "openai"},
+		{"o3-mini", "openai"},
+		{"gpt-4", "openai-legacy"},
+		{"gpt-4-turbo", "openai-legacy"},
+		{"GPT-3.5-turbo", "openai-legacy"},
+		{"claude-3-5-sonnet", "anthropic"},
+		{"glm-4-flash", "glm"},
+		{"ollama/llama3", "default"},
+	}
+	for _, tt := range tests {
+		if got := ForModel(tt.model).Family; got != tt.want {
+			t.Errorf("ForModel(%q) = %s, want %s", tt.model, got, tt.want)
+		}
+	}
+}
+
+func TestCount(t *testing.T) {
+	tests := []struct {
+		name      string
+		estimator *Estimator
+		text      string
+		want      int
+	}{
+		// OpenAI 模型族按 BPE 精确分词
+		{"o200k empty", openAIEstimator, "", 0},
+		{"o200k words", openAIEstimator, "tiktoken is great!", 6},
+		{"o200k cjk", openAIEstimator, "你好，世界", 3},
+		{"cl100k words", openAILegacyEstimator, "tiktoken is great!", 6},
+		{"cl100k cjk", openAILegacyEstimator, "你好，世界", 6},
+		// 其余模型族按预切分与压缩率估算
+		{"heuristic empty", defaultEstimator, "", 0},
+		{"heuristic words", defaultEstimator, "hello world", 4},
+		{"heuristic digits", defaultEstimator, "2024", 2},
+		{"heuristic punctuation", defaultEstimator, "a, b.", 4},
+		{"heuristic cjk", defaultEstimator, "你好，世界", 5},
+		{"anthropic cjk", anthropicEstimator, "你好", 3},
+		{"glm cjk", glmEstimator, "你好世界", 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.estimator.Count(tt.text); got != tt.want {
+				t.Errorf("Count(%q) = %d, want %d", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	const text = "The quick brown fox jumps over the lazy dog"
+	tests := []struct {
+		name      string
+		estimator *Estimator
+		text      string
+		maxTokens int
+		want      string
+	}{
+		{"bpe fits", openAIEstimator, "hello world", 5, "hello world"},
+		{"bpe cut", openAIEstimator, text, 3, "The quick brown"},
+		{"bpe zero", openAIEstimator, text, 0, ""},
+		{"heuristic fits", defaultEstimator, "hello world", 5, "hello world"},
+		{"heuristic cut", defaultEstimator, text, 3, "The quick"},
+		{"heuristic cjk", defaultEstimator, "你好，世界", 2, "你好"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.estimator.Truncate(tt.text, tt.maxTokens)
+			if got != tt.want {
+				t.Errorf("Truncate(%q, %d) = %q, want %q", tt.text, tt.maxTokens, got, tt.want)
+			}
+			if count := tt.estimator.Count(got); count > tt.maxTokens {
+				t.Errorf("Truncate(%q, %d) has %d tokens", tt.text, tt.maxTokens, count)
+			}
+		})
+	}
+}
+
+func TestCountMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		message models.Message
+		want    int
+	}{
+		{"plain text", models.Message{Role: "user", Content: "hello world"}, 4 + 4},
+		{"parts", models.Message{Role: "user", Parts: []models.ContentPart{
+			{Type: models.PartText, Text: "hello world"},
+			{Type: models.PartImageURL},
+			{Type: models.PartFile},
+		}}, 4 + 4 + 800 + 2000},
+		{"tool call", models.Message{Role: "assistant", ToolCalls: []models.ToolCall{{Name: "f", Arguments: "{}"}}}, 4 + 1 + 2 + 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := defaultEstimator.CountMessage(tt.message); got != tt.want {
+				t.Errorf("CountMessage() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package tokenizer
+
+import (
+	"strings"
+
+	"github.com/EthanGuo-coder/llm-backend-api/config"
+)
+
+// defaultContextWindow 未知模型的上下文窗口
+const defaultContextWindow = 8192
+
+// builtinLimits 内置的模型上下文窗口，按最长前缀匹配
+var builtinLimits = map[string]int{
+	"gpt-4o":        128000,
+	"gpt-4.1":       1047576,
+	"gpt-4-turbo":   128000,
+	"gpt-4":         8192,
+	"gpt-3.5-turbo": 16385,
+	"o1":            200000,
+	"o3":            200000,
+	"o4-mini":       200000,
+	"claude":        200000,
+	"glm-4":         128000,
+	"glm-4v":        8192,
+	"ollama/":       4096, // Ollama 默认 num_ctx
+}
+
+// ContextWindow 返回模型的上下文窗口大小，配置中的限制优先于内置表
+func ContextWindow(model string) int {
+	name := strings.ToLower(model)
+	for _, limit := range config.AppConfig.Context.Limits {
+		if strings.HasPrefix(name, strings.ToLower(limit.ModelPrefix)) {
+			return limit.ContextWindow
+		}
+	}
+
+	window, matched := defaultContextWindow, ""
+	for prefix, size := range builtinLimits {
+		if strings.HasPrefix(name, prefix) && len(prefix) > len(matched) {
+			window, matched = size, prefix
+		}
+	}
+	return window
+}
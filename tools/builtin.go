@@ -0,0 +1,47 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/EthanGuo-coder/llm-backend-api/models"
+)
+
+// builtinTools 内置工具列表
+func builtinTools() []*Tool {
+	return []*Tool{
+		{
+			Name:        "get_current_time",
+			Description: "Get the current date and time, optionally in a given IANA time zone such as Asia/Shanghai.",
+			Parameters: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"timezone": {"type": "string", "description": "IANA time zone name, defaults to the server time zone"}
+				}
+			}`),
+			Handler: currentTime,
+		},
+	}
+}
+
+// currentTime 返回指定时区的当前时间
+func currentTime(_ context.Context, _ *models.Conversation, arguments json.RawMessage) (string, error) {
+	var args struct {
+		Timezone string `json:"timezone"`
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	location := time.Local
+	if args.Timezone != "" {
+		loaded, err := time.LoadLocation(args.Timezone)
+		if err != nil {
+			return "", fmt.Errorf("unknown time zone: %s", args.Timezone)
+		}
+		location = loaded
+	}
+	return time.Now().In(location).Format(time.RFC3339), nil
+}
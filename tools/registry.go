@@ -0,0 +1,108 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/EthanGuo-coder/llm-backend-api/config"
+	"github.com/EthanGuo-coder/llm-backend-api/models"
+)
+
+// Handler 工具执行函数，返回交给模型的结果文本
+type Handler func(ctx context.Context, conversation *models.Conversation, arguments json.RawMessage) (string, error)
+
+// Tool 服务端工具
+type Tool struct {
+	Name        string
+	Description string
+	Parameters  json.RawMessage // 参数的 JSON Schema
+	Handler     Handler
+	// Available 判断工具对会话是否可用，为空表示始终可用
+	Available func(conversation *models.Conversation) bool
+}
+
+// registry 已注册的工具
+var registry = map[string]*Tool{}
+
+// Register 注册工具，名称重复时返回错误
+func Register(tool *Tool) error {
+	if tool.Name == "" || tool.Handler == nil {
+		return fmt.Errorf("tool name and handler are required")
+	}
+	if _, exists := registry[tool.Name]; exists {
+		return fmt.Errorf("tool %q is already registered", tool.Name)
+	}
+	registry[tool.Name] = tool
+	return nil
+}
+
+// InitializeTools 注册内置工具，并校验配置中启用的工具均已注册。
+// 其他包提供的工具需在此之前注册。
+func InitializeTools() error {
+	for _, tool := range builtinTools() {
+		if err := Register(tool); err != nil {
+			return err
+		}
+	}
+	for _, name := range config.AppConfig.Tools.Enabled {
+		if _, ok := registry[name]; !ok {
+			return fmt.Errorf("unknown tool: %s", name)
+		}
+	}
+	return nil
+}
+
+// Enabled 判断工具是否已在配置中启用
+func Enabled(name string) bool {
+	for _, enabled := range config.AppConfig.Tools.Enabled {
+		if enabled == name {
+			return true
+		}
+	}
+	return false
+}
+
+// available 工具已启用且对会话可用
+func available(tool *Tool, conversation *models.Conversation) bool {
+	return Enabled(tool.Name) && (tool.Available == nil || tool.Available(conversation))
+}
+
+// Definitions 返回对会话可用的工具声明，按配置顺序排列
+func Definitions(conversation *models.Conversation) []models.ToolDefinition {
+	var definitions []models.ToolDefinition
+	for _, name := range config.AppConfig.Tools.Enabled {
+		if tool, ok := registry[name]; ok && available(tool, conversation) {
+			definitions = append(definitions, models.ToolDefinition{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.Parameters,
+			})
+		}
+	}
+	return definitions
+}
+
+// Execute 执行一次工具调用，超时时间由配置决定
+func Execute(ctx context.Context, conversation *models.Conversation, call models.ToolCall) (string, error) {
+	tool, ok := registry[call.Name]
+	if !ok || !available(tool, conversation) {
+		return "", fmt.Errorf("unknown tool: %s", call.Name)
+	}
+
+	arguments := json.RawMessage(call.Arguments)
+	if len(arguments) == 0 {
+		arguments = json.RawMessage("{}")
+	}
+	if !json.Valid(arguments) {
+		return "", fmt.Errorf("invalid arguments for tool %s: %s", call.Name, call.Arguments)
+	}
+
+	if timeout := config.AppConfig.Tools.Timeout; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+		defer cancel()
+	}
+	return tool.Handler(ctx, conversation, arguments)
+}